@@ -2,19 +2,45 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cbednarski/hostess/hostess"
 )
 
 var ErrParsingHostsFile = errors.New("Errors while parsing hostsfile. Please resolve any conflicts and try again.")
+var ErrNotFormatted = errors.New("Hosts file is not formatted or contains duplicates or conflicts. Run hostess fmt to fix it.")
+var ErrHostsDiffer = errors.New("Hosts entries differ")
 
 type Options struct {
-	Preview bool
+	Preview     bool
+	PreviewFull bool
+	Color       bool
+	Check       bool
+	LockTimeout time.Duration
+	Profile     string
+	TTL         time.Duration
+	Until       string
+	Comment     string
+	Metadata    map[string]string
+	Prune       bool
+	JSON        bool
+	Plan        string
+	Format      string
+	Origin      string
+	Serial      uint
+	Ports       string
+	Match       string
+	Network     string
 }
 
 // PrintErrLn will print to stderr followed by a newline
@@ -36,39 +62,175 @@ func LoadHostfile(options *Options) (*hostess.Hostfile, error) {
 			err = ErrParsingHostsFile
 		}
 
+		invalidDomain := false
 		for _, currentErr := range errs {
 			PrintErrLn(currentErr)
-			// If we find a duplicate we'll notify the user and continue. For
-			// other errors we'll bail out.
-			if !strings.Contains(currentErr.Error(), "duplicate hostname entry") {
+			// If we find a duplicate or a line we can't parse we'll notify the
+			// user and continue, since the line is written back as it was. For
+			// conflicts and other errors we'll bail out.
+			if !IsParseWarning(currentErr) || currentErr == hosts.BlockError() {
 				err = ErrParsingHostsFile
 			}
+			var domain *hostess.InvalidDomainError
+			if errors.As(currentErr, &domain) {
+				invalidDomain = true
+			}
+		}
+		if invalidDomain && hostess.IsStrict() {
+			PrintErrLn(fmt.Errorf("To accept these hostnames anyway, unset %s", hostess.EnvHostessNames))
 		}
 	}
 
 	return hosts, err
 }
 
-// SaveOrPreview will display or write the Hostfile
+// IsParseWarning returns true if err is a problem in the hosts file that we
+// can safely ignore: a duplicate entry, or a line that we keep as-is because
+// we can't parse it.
+func IsParseWarning(err error) bool {
+	switch err.(type) {
+	case *hostess.DuplicateError, *hostess.MalformedLineError, *hostess.InvalidIPError, *hostess.InvalidDomainError:
+		return true
+	}
+	return false
+}
+
+// LockHostfile takes the hosts file lock so other hostess processes can't
+// change the hosts file between when we load it and when we save it. Call the
+// returned function to release the lock.
+func LockHostfile(options *Options) (func(), error) {
+	lock, err := hostess.AcquireLock(hostess.GetHostsPath(), options.LockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to lock %s. (error: %s)", hostess.GetHostsPath(), err)
+	}
+	return func() {
+		if err := lock.Release(); err != nil {
+			PrintErrLn(err)
+		}
+	}, nil
+}
+
+// SaveOrPreview will display or write the Hostfile. Expired entries are
+// swept first; see SweepExpired.
 func SaveOrPreview(options *Options, hostfile *hostess.Hostfile) error {
-	// If -n is passed, no-op and output the resultant hosts file to stdout.
-	// Otherwise it's for real and we're going to write it.
+	SweepExpired(options, hostfile)
+	return WriteOrPreview(options, hostfile)
+}
+
+// WriteOrPreview is like SaveOrPreview but doesn't sweep expired entries, for
+// when we need to make exactly the changes we were asked to, like apply -plan.
+func WriteOrPreview(options *Options, hostfile *hostess.Hostfile) error {
+	// If -n is passed, no-op and show what would change in the hosts file (or
+	// the entire resultant file with -full). Otherwise it's for real and we're
+	// going to write it.
 	if options.Preview {
-		fmt.Printf("%s", hostfile.Format())
+		if options.PreviewFull {
+			fmt.Printf("%s", hostfile.Format())
+			return nil
+		}
+		PrintDiff(options, hostfile.Path, hostfile.Path+" (preview)", hostfile.GetData(), hostfile.Format())
 		return nil
 	}
 
+	// Pick up any changes someone else made since we loaded the hosts file,
+	// so they're included in the backup.
+	if err := hostfile.Rebase(); err != nil {
+		return err
+	}
+
+	if err := BackupHostfile(hostfile); err != nil {
+		return err
+	}
+
 	if err := hostfile.Save(); err != nil {
 		return fmt.Errorf("Unable to write to %s. (error: %s)", hostess.GetHostsPath(), err)
 	}
 
+	PruneBackups(hostfile)
+	return nil
+}
+
+// SweepExpired removes expired entries from the hosts file, or disables them
+// if HOSTESS_EXPIRE=disable. It returns the number of entries affected.
+func SweepExpired(options *Options, hostfile *hostess.Hostfile) int {
+	action := "Removed"
+	var swept hostess.Hostlist
+	if hostess.ShouldDisableExpired() {
+		action = "Disabled"
+		swept = hostfile.Hosts.DisableExpired(time.Now())
+	} else {
+		swept = hostfile.Hosts.RemoveExpired(time.Now())
+	}
+
+	// With -n we'll see the change in the diff
+	if !options.Preview {
+		for _, hostname := range swept {
+			fmt.Printf("%s expired %s\n", action, hostname.FormatHuman())
+		}
+	}
+	return len(swept)
+}
+
+// BackupHostfile saves a copy of the hosts file as we loaded it, according to
+// HOSTESS_BACKUP_DIR and HOSTESS_BACKUPS
+func BackupHostfile(hostfile *hostess.Hostfile) error {
+	count, err := hostess.GetBackupCount()
+	if err != nil {
+		return err
+	}
+
+	if _, err := hostfile.Backup(hostess.GetBackupDir(), count); err != nil {
+		return fmt.Errorf("Unable to back up %s. (error: %s)", hostfile.Path, err)
+	}
+
 	return nil
 }
 
-// StrPadRight adds spaces to the right of a string until it reaches length.
-// If the input string is already that long, do nothing.
+// PruneBackups deletes the oldest backups so only HOSTESS_BACKUPS remain. We
+// only do this after the hosts file was written, so a failed write never costs
+// us a good backup. Since the hosts file has already changed by then, problems
+// are shown to the user but are not an error.
+func PruneBackups(hostfile *hostess.Hostfile) {
+	count, err := hostess.GetBackupCount()
+	if err != nil {
+		PrintErrLn(err)
+		return
+	}
+
+	if err := hostess.PruneBackups(hostess.GetBackupDir(), hostfile.Path, count); err != nil {
+		PrintErrLn(fmt.Errorf("Unable to remove old backups of %s. (error: %s)", hostfile.Path, err))
+	}
+}
+
+// PrintDiff shows the differences between a and b as a unified diff,
+// colorized if -color was passed. If there are no differences we print
+// nothing.
+func PrintDiff(options *Options, nameA, nameB string, a, b []byte) {
+	diff := hostess.UnifiedDiff(nameA, nameB, a, b)
+	if options.Color {
+		diff = hostess.ColorDiff(diff)
+	}
+	fmt.Printf("%s", diff)
+}
+
+// FormatTTL shows how much longer an entry will last in a compact form, e.g.
+// 3d, 1h59m, 5m, or 30s
+func FormatTTL(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", d/time.Hour, (d%time.Hour)/time.Minute)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// StrPadRight adds spaces to the right of a string until it reaches length
+// characters. If the input string is already that long, do nothing.
 func StrPadRight(input string, length int) string {
-	minimum := len(input)
+	minimum := utf8.RuneCountInString(input)
 	if length <= minimum {
 		return input
 	}
@@ -84,8 +246,42 @@ func Add(options *Options, hostname, ip string) error {
 	if err != nil {
 		return err
 	}
+	if options.Profile != "" {
+		if err := hostess.ValidateProfileName(options.Profile); err != nil {
+			return err
+		}
+		newHostname.Profile = options.Profile
+	}
+	switch {
+	case options.TTL != 0 && options.Until != "":
+		return errors.New("Use either -ttl or -until, not both")
+	case options.TTL < 0:
+		return errors.New("-ttl must be positive, e.g. 2h")
+	case options.TTL > 0:
+		newHostname.Expires = time.Now().Add(options.TTL).Truncate(time.Second)
+	case options.Until != "":
+		newHostname.Expires, err = hostess.ParseUntil(options.Until)
+		if err != nil {
+			return err
+		}
+	}
+	// Otherwise we'd add the entry and immediately remove it again
+	if !newHostname.Expires.IsZero() && !newHostname.Expires.After(time.Now()) {
+		return fmt.Errorf("Expiry time %s has already passed", newHostname.Expires.Format(time.RFC3339))
+	}
+	if err := hostess.ValidateComment(options.Comment); err != nil {
+		return err
+	}
+	if err := hostess.ValidateMetadata(options.Metadata); err != nil {
+		return err
+	}
+	newHostname.Comment = options.Comment
+	if len(options.Metadata) > 0 {
+		newHostname.Metadata = options.Metadata
+	}
 
-	replaced := hostsfile.Hosts.ContainsDomain(newHostname.Domain)
+	profile := hostsfile.Hosts.FilterByProfile(options.Profile)
+	replaced := profile.ContainsDomain(newHostname.Domain)
 	// Note that Add() may return an error, but they are informational only. We
 	// don't actually care what the error is -- we just want to add the
 	// hostname and save the file. This way the behavior is idempotent.
@@ -137,7 +333,10 @@ func Has(options *Options, hostname string) error {
 		return err
 	}
 
-	found := hostsfile.Hosts.ContainsDomain(hostname)
+	// The managed block only limits what we change, so we look at the
+	// whole file
+	unmanaged := hostsfile.UnmanagedHosts()
+	found := hostsfile.Hosts.ContainsDomain(hostname) || unmanaged.ContainsDomain(hostname)
 	if found {
 		fmt.Printf("Found %s in %s\n", hostname, hostess.GetHostsPath())
 	} else {
@@ -193,44 +392,221 @@ func Disable(options *Options, hostname string) error {
 	return nil
 }
 
-// List command shows a list of hostnames in the hosts file
+// List command shows a list of hostnames in the hosts file. With
+// HOSTESS_BLOCK, entries outside the managed block are marked (unmanaged).
 func List(options *Options) error {
 	hostsfile, err := LoadHostfile(options)
 	if err != nil {
 		return err
 	}
 
+	hostnames := append(hostess.Hostlist{}, hostsfile.Hosts...)
+	unmanaged := map[*hostess.Hostname]bool{}
+	for _, hostname := range hostsfile.UnmanagedHosts() {
+		unmanaged[hostname] = true
+		hostnames = append(hostnames, hostname)
+	}
+	hostnames.Sort()
+
 	widestHostname := 0
 	widestIP := 0
 
-	for _, hostname := range hostsfile.Hosts {
-		dlen := len(hostname.Domain)
+	// Show Unicode domains the way they were typed, not as punycode
+	for _, hostname := range hostnames {
+		dlen := utf8.RuneCountInString(hostess.ToUnicode(hostname.Domain))
 		if dlen > widestHostname {
 			widestHostname = dlen
 		}
-		ilen := len(hostname.IP)
+		ilen := len(hostname.FormatIP())
 		if ilen > widestIP {
 			widestIP = ilen
 		}
 	}
 
-	for _, hostname := range hostsfile.Hosts {
-		fmt.Printf("%s -> %s %s\n",
-			StrPadRight(hostname.Domain, widestHostname),
-			StrPadRight(hostname.IP.String(), widestIP),
+	for _, hostname := range hostnames {
+		line := fmt.Sprintf("%s -> %s %s",
+			StrPadRight(hostess.ToUnicode(hostname.Domain), widestHostname),
+			StrPadRight(hostname.FormatIP(), widestIP),
 			hostname.FormatEnabled())
+		if hostname.Profile != "" {
+			line += " " + hostname.Profile
+		}
+		if unmanaged[hostname] {
+			line += " (unmanaged)"
+		}
+		if !hostname.Expires.IsZero() {
+			if remaining := time.Until(hostname.Expires); remaining > 0 {
+				line += fmt.Sprintf(" (expires in %s)", FormatTTL(remaining))
+			} else {
+				line += " (expired)"
+			}
+		}
+		if comment := hostess.TrimWS(hostname.Comment + " " + hostname.FormatMetadata()); comment != "" {
+			line += " # " + comment
+		}
+		fmt.Println(line)
 	}
 
 	return nil
 }
 
-// Format command removes duplicates from the hosts file
+// GC command removes (or disables, if HOSTESS_EXPIRE=disable) entries that
+// have expired
+func GC(options *Options) error {
+	hostsfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	if len(hostsfile.Hosts.Expired(time.Now())) == 0 {
+		fmt.Printf("No expired entries in %s; nothing to do\n", hostess.GetHostsPath())
+		return nil
+	}
+
+	// SaveOrPreview sweeps the expired entries for us
+	return SaveOrPreview(options, hostsfile)
+}
+
+// ProfileCreate command adds an empty profile to the hosts file
+func ProfileCreate(options *Options, name string) error {
+	hostsfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	if err := hostsfile.CreateProfile(name); err != nil {
+		if err == hostess.ErrProfileExists {
+			// We already have what the user asked for
+			fmt.Printf("Profile %s already exists\n", name)
+			return nil
+		}
+		return err
+	}
+
+	if err := SaveOrPreview(options, hostsfile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created profile %s\n", name)
+	return nil
+}
+
+// ProfileEnable command enables all of the hostnames in a profile, and
+// disables entries for the same hostnames in other profiles
+func ProfileEnable(options *Options, name string) error {
+	return setProfile(options, name, true)
+}
+
+// ProfileDisable command disables all of the hostnames in a profile
+func ProfileDisable(options *Options, name string) error {
+	return setProfile(options, name, false)
+}
+
+func setProfile(options *Options, name string, enabled bool) error {
+	hostsfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	if !hostsfile.HasProfile(name) {
+		return fmt.Errorf("Profile %s not found in %s", name, hostess.GetHostsPath())
+	}
+
+	if enabled {
+		err = hostsfile.Hosts.EnableProfile(name)
+	} else {
+		err = hostsfile.Hosts.DisableProfile(name)
+	}
+	if err == hostess.ErrProfileNotFound {
+		fmt.Printf("Profile %s is empty; nothing to do\n", name)
+		return nil
+	}
+
+	if err := SaveOrPreview(options, hostsfile); err != nil {
+		return err
+	}
+
+	if enabled {
+		fmt.Printf("Enabled profile %s\n", name)
+	} else {
+		fmt.Printf("Disabled profile %s\n", name)
+	}
+	return nil
+}
+
+// ProfileList command lists the profiles in the hosts file, with how many
+// hostnames each one has and whether they are enabled
+func ProfileList(options *Options) error {
+	hostsfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	profiles := hostsfile.ListProfiles()
+	widest := 0
+	for _, name := range profiles {
+		if len(name) > widest {
+			widest = len(name)
+		}
+	}
+
+	for _, name := range profiles {
+		hostnames := hostsfile.Hosts.FilterByProfile(name)
+		enabled := 0
+		for _, hostname := range hostnames {
+			if hostname.Enabled {
+				enabled++
+			}
+		}
+
+		state := "(Mixed)"
+		switch enabled {
+		case len(hostnames):
+			state = "(On)"
+		case 0:
+			state = "(Off)"
+		}
+		fmt.Printf("%s %d hostnames %s\n", StrPadRight(name, widest), len(hostnames), state)
+	}
+
+	return nil
+}
+
+// ProfileRemove command removes a profile and all of its hostnames from the
+// hosts file
+func ProfileRemove(options *Options, name string) error {
+	hostsfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	if err := hostsfile.RemoveProfile(name); err != nil {
+		if err == hostess.ErrProfileNotFound {
+			fmt.Printf("Profile %s not found in %s\n", name, hostess.GetHostsPath())
+			return nil
+		}
+		return err
+	}
+
+	if err := SaveOrPreview(options, hostsfile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted profile %s\n", name)
+	return nil
+}
+
+// Format command removes duplicates from the hosts file and rewrites its
+// entries in the canonical format. Comments and blank lines are kept, and the
+// entries between them are sorted.
 func Format(options *Options) error {
 	hostsfile, err := LoadHostfile(options)
 	if err != nil {
 		return err
 	}
 
+	hostsfile.Canonicalize()
+
 	if bytes.Equal(hostsfile.GetData(), hostsfile.Format()) {
 		fmt.Printf("%s is already formatted and contains no dupes or conflicts; nothing to do\n", hostess.GetHostsPath())
 		return nil
@@ -239,13 +615,110 @@ func Format(options *Options) error {
 	return SaveOrPreview(options, hostsfile)
 }
 
-// Dump command outputs hosts file contents as JSON
+// FormatCheck command reports whether each of the named hosts files (or the
+// system hosts file, if none are named) is already formatted and free of
+// duplicates and conflicts. Like fmt, it only looks at the entries, so comments
+// and blank lines are fine. It prints a diff of the changes fmt would make and
+// any problems found while parsing, and never changes the files.
+func FormatCheck(options *Options, paths []string) error {
+	if len(paths) == 0 {
+		paths = []string{hostess.GetHostsPath()}
+	}
+
+	var result error
+	for _, path := range paths {
+		hostfile := hostess.NewHostfile()
+		hostfile.Path = path
+		if err := hostfile.Read(); err != nil {
+			return err
+		}
+
+		errs := hostfile.Parse()
+		for _, err := range errs {
+			PrintErrLn(err)
+		}
+
+		hostfile.Canonicalize()
+		formatted := hostfile.Format()
+		PrintDiff(options, path, path+" (formatted)", hostfile.GetData(), formatted)
+
+		if len(errs) > 0 || !bytes.Equal(hostfile.GetData(), formatted) {
+			result = ErrNotFormatted
+		}
+	}
+
+	return result
+}
+
+// Dump command outputs hosts file contents as JSON, or with -format as
+// configuration for another program. -match and -network limit which entries
+// are included.
 func Dump(options *Options) error {
 	hostsfile, err := LoadHostfile(options)
 	if err != nil {
 		return err
 	}
 
+	if options.Match != "" {
+		hostsfile.Hosts, err = hostsfile.Hosts.FilterByDomainPattern(options.Match)
+		if err != nil {
+			return fmt.Errorf("Invalid -match pattern %q: %s", options.Match, err)
+		}
+	}
+	if options.Network != "" {
+		_, network, err := net.ParseCIDR(options.Network)
+		if err != nil {
+			return fmt.Errorf("Invalid -network %q; expected an IP range like 10.0.0.0/8", options.Network)
+		}
+		hostsfile.Hosts = hostsfile.Hosts.FilterByNetwork(network)
+	}
+
+	switch options.Format {
+	case "", "json":
+	case "dnsmasq":
+		fmt.Printf("%s", hostsfile.Hosts.FormatDnsmasq())
+		return nil
+	case "dnsmasq-address":
+		fmt.Printf("%s", hostsfile.Hosts.FormatDnsmasqAddress())
+		return nil
+	case "unbound":
+		fmt.Printf("%s", hostsfile.Hosts.FormatUnbound())
+		return nil
+	case "coredns":
+		fmt.Printf("%s", hostsfile.Hosts.FormatCoreDNS())
+		return nil
+	case "bind":
+		return DumpBind(options, hostsfile)
+	case "kubernetes":
+		fmt.Printf("%s", hostsfile.Hosts.FormatHostAliases())
+		return nil
+	case "docker":
+		fmt.Printf("%s", hostsfile.Hosts.FormatDockerAddHost())
+		return nil
+	case "compose":
+		fmt.Printf("%s", hostsfile.Hosts.FormatComposeExtraHosts())
+		return nil
+	case "curl":
+		ports, err := ParsePorts(options.Ports)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", hostsfile.Hosts.FormatCurlResolve(ports))
+		return nil
+	case "chrome":
+		fmt.Printf("%s", hostsfile.Hosts.FormatChromeRules())
+		return nil
+	case "csv", "tsv":
+		data, err := hostsfile.Hosts.DumpCSV(CSVComma(options.Format))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", data)
+		return nil
+	default:
+		return fmt.Errorf("Unknown dump format %q", options.Format)
+	}
+
 	jsonbytes, err := hostsfile.Hosts.Dump()
 	if err != nil {
 		return err
@@ -255,8 +728,59 @@ func Dump(options *Options) error {
 	return nil
 }
 
-// Apply command adds hostnames to the hosts file from JSON
+// ParsePorts parses a comma-separated list of ports like 80,443
+func ParsePorts(list string) ([]int, error) {
+	ports := []int{}
+	for _, field := range strings.Split(list, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("Invalid port %q in -port %s", field, list)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// DumpBind outputs the forward zone for -origin and the reverse zones for
+// the hosts file, one after the other. Each zone starts with a comment naming
+// it so you can split them into separate files. The SOA serial is -serial, or
+// the Unix time so it goes up every time you export.
+func DumpBind(options *Options, hostsfile *hostess.Hostfile) error {
+	if options.Origin == "" {
+		return errors.New("-format bind needs -origin, e.g. -origin example.internal")
+	}
+	if options.Serial > math.MaxUint32 {
+		return fmt.Errorf("-serial must be at most %d", uint32(math.MaxUint32))
+	}
+	serial := uint32(options.Serial)
+	if serial == 0 {
+		serial = uint32(time.Now().Unix())
+	}
+	zones, err := hostsfile.Hosts.BindZones(options.Origin, serial)
+	if err != nil {
+		return err
+	}
+	for index, zone := range zones {
+		if index > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("; zone %s\n%s", zone.Name, zone.Data)
+	}
+	return nil
+}
+
+// Apply command adds hostnames to the hosts file from JSON, or from CSV or
+// TSV with -format. With -prune it also removes hostnames that are not in the
+// file.
 func Apply(options *Options, filename string) error {
+	switch options.Format {
+	case "", "json":
+	case "csv", "tsv":
+		return ApplyCSV(options, filename)
+	default:
+		return fmt.Errorf("Unknown apply format %q", options.Format)
+	}
+
 	jsonbytes, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("Unable to read JSON from %s: %s", filename, err)
@@ -267,14 +791,325 @@ func Apply(options *Options, filename string) error {
 		return err
 	}
 
-	if err := hostfile.Hosts.Apply(jsonbytes); err != nil {
+	var pruned hostess.Hostlist
+	if options.Prune {
+		if hostfile.Block == "" {
+			return hostess.ErrPruneWithoutBlock
+		}
+		pruned, err = hostfile.Hosts.Sync(jsonbytes)
+	} else {
+		err = hostfile.Hosts.Apply(jsonbytes)
+	}
+	if err != nil {
 		return fmt.Errorf("Error applying changes to hosts file: %s", err)
 	}
 
+	return SaveApplied(options, hostfile, filename, pruned)
+}
+
+// ApplyCSV adds hostnames to the hosts file from a CSV or TSV file, e.g. one
+// exported from a spreadsheet. See hostess.ParseCSV.
+func ApplyCSV(options *Options, filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read %s: %s", filename, err)
+	}
+
+	hostnames, errs := hostess.ParseCSV(filename, data, CSVComma(options.Format))
+	if len(errs) > 0 {
+		for _, err := range errs {
+			PrintErrLn(err)
+		}
+		return fmt.Errorf("Errors while parsing %s. Please fix them and try again.", filename)
+	}
+
+	hostfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	var pruned hostess.Hostlist
+	if options.Prune {
+		if hostfile.Block == "" {
+			return hostess.ErrPruneWithoutBlock
+		}
+		pruned = hostfile.Hosts.SyncHostnames(hostnames)
+	} else {
+		for _, hostname := range hostnames {
+			hostfile.Hosts.Add(hostname)
+		}
+	}
+
+	return SaveApplied(options, hostfile, filename, pruned)
+}
+
+// CSVComma returns the field separator for -format csv or tsv
+func CSVComma(format string) rune {
+	if format == "tsv" {
+		return '\t'
+	}
+	return ','
+}
+
+// SaveApplied saves the hosts file after apply, and lists the hostnames that
+// -prune removed
+func SaveApplied(options *Options, hostfile *hostess.Hostfile, filename string, pruned hostess.Hostlist) error {
 	if err := SaveOrPreview(options, hostfile); err != nil {
 		return err
 	}
+	if !options.Preview {
+		for _, hostname := range pruned {
+			fmt.Printf("Removed %s\n", hostname.FormatHuman())
+		}
+	}
 
 	fmt.Printf("%s applied\n", filename)
 	return nil
 }
+
+// ReadHostlist reads the hosts entries in path, which may be a hosts file or
+// JSON from hostess dump
+func ReadHostlist(path string) (hostess.Hostlist, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := hostess.NewHostlist()
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		// Unlike apply we aren't adding these names to the hosts file, so a
+		// dump of a hosts file with legacy names like my_host can be read the
+		// same way as the hosts file itself
+		var decoded hostess.Hostlist
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("Unable to read JSON from %s: %s", path, err)
+		}
+		for _, hostname := range decoded {
+			hosts.Add(hostname)
+		}
+		return *hosts, nil
+	}
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = path
+	if err := hostfile.Read(); err != nil {
+		return nil, err
+	}
+	err = nil
+	for _, currentErr := range hostfile.Parse() {
+		PrintErrLn(currentErr)
+		var duplicate *hostess.DuplicateError
+		if !errors.As(currentErr, &duplicate) {
+			err = ErrParsingHostsFile
+		}
+	}
+	return hostfile.Hosts, err
+}
+
+// Diff command shows the changes between the hosts entries in two files. Each
+// may be a hosts file or JSON from hostess dump.
+func Diff(options *Options, pathA, pathB string) error {
+	hostsA, err := ReadHostlist(pathA)
+	if err != nil {
+		return err
+	}
+	hostsB, err := ReadHostlist(pathB)
+	if err != nil {
+		return err
+	}
+
+	changes := hostess.Diff(hostsA, hostsB)
+	if options.JSON {
+		jsonbytes, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", jsonbytes)
+	} else {
+		for _, change := range changes {
+			fmt.Println(change.String())
+		}
+	}
+
+	if len(changes) > 0 {
+		return ErrHostsDiffer
+	}
+	return nil
+}
+
+// Plan command shows the changes that apply would make with filename, without
+// changing the hosts file. With -json the plan can be saved and used with
+// apply -plan.
+func Plan(options *Options, filename string) error {
+	jsonbytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read JSON from %s: %s", filename, err)
+	}
+
+	hostfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	plan, err := hostfile.Plan(jsonbytes, options.Prune)
+	if err != nil {
+		return fmt.Errorf("Error planning changes to hosts file: %s", err)
+	}
+
+	if !options.JSON {
+		fmt.Printf("%s", plan.Format())
+		return nil
+	}
+	planbytes, err := plan.Dump()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", planbytes)
+	return nil
+}
+
+// ApplyPlan command makes the changes in a plan from plan -json, as long as
+// the hosts file hasn't changed since the plan was made
+func ApplyPlan(options *Options, filename string) error {
+	planbytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read plan from %s: %s", filename, err)
+	}
+	plan, err := hostess.LoadPlan(planbytes)
+	if err != nil {
+		return fmt.Errorf("Unable to read plan from %s: %s", filename, err)
+	}
+
+	hostfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	if err := plan.Apply(hostfile); err != nil {
+		return fmt.Errorf("Unable to apply %s: %s", filename, err)
+	}
+
+	// The plan already includes any entries that had expired when it was made
+	if err := WriteOrPreview(options, hostfile); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s applied\n", filename)
+	return nil
+}
+
+// Import command adds hostnames from another program's configuration, e.g. a
+// dnsmasq config file, to the hosts file
+func Import(options *Options, filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read %s: %s", filename, err)
+	}
+
+	var hostnames hostess.Hostlist
+	var errs []error
+	switch options.Format {
+	case "dnsmasq":
+		hostnames, errs = hostess.ParseDnsmasq(filename, data)
+	case "unbound":
+		hostnames, errs = hostess.ParseUnbound(filename, data)
+	case "bind":
+		hostnames, errs = hostess.ParseBind(filename, data, options.Origin)
+	case "":
+		return fmt.Errorf("Usage: %s import -format dnsmasq|unbound|bind <filename>", os.Args[0])
+	default:
+		return fmt.Errorf("Unknown import format %q", options.Format)
+	}
+	// Lines we can't parse are skipped with a warning, like in the hosts file,
+	// but invalid hostnames and IPs stop the import
+	failed := false
+	for _, err := range errs {
+		PrintErrLn(err)
+		if _, ok := err.(*hostess.MalformedLineError); !ok {
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("Errors while parsing %s. Please fix them and try again.", filename)
+	}
+
+	hostfile, err := LoadHostfile(options)
+	if err != nil {
+		return err
+	}
+
+	// Duplicates and conflicts are reported, but like add, the last entry
+	// wins
+	for _, hostname := range hostnames {
+		if err := hostfile.Hosts.Add(hostname); err != nil {
+			PrintErrLn(err)
+		}
+	}
+
+	if err := SaveOrPreview(options, hostfile); err != nil {
+		return err
+	}
+	if !options.Preview {
+		fmt.Printf("Imported %d hostnames from %s\n", len(hostnames), filename)
+	}
+	return nil
+}
+
+// Backups command lists the backups of the hosts file, newest first
+func Backups(options *Options) error {
+	backups, err := hostess.ListBackups(hostess.GetBackupDir(), hostess.GetHostsPath())
+	if err != nil {
+		return err
+	}
+
+	if len(backups) == 0 {
+		fmt.Printf("No backups of %s found in %s\n", hostess.GetHostsPath(), hostess.GetBackupDir())
+		return nil
+	}
+
+	for _, backup := range backups {
+		fmt.Printf("%s  %s  %s\n", backup.ID, backup.Time.Local().Format("2006-01-02 15:04:05"), backup.Path)
+	}
+
+	return nil
+}
+
+// Restore command replaces the hosts file with a backup. If id is blank we
+// will use the newest backup. The current hosts file is backed up first so
+// the restore can be undone.
+func Restore(options *Options, id string) error {
+	backup, err := hostess.FindBackup(hostess.GetBackupDir(), hostess.GetHostsPath(), id)
+	if err != nil {
+		if err == hostess.ErrBackupNotFound && id == "" {
+			return fmt.Errorf("No backups of %s found in %s", hostess.GetHostsPath(), hostess.GetBackupDir())
+		}
+		return err
+	}
+
+	hostfile := hostess.NewHostfile()
+	if err := hostfile.Read(); err != nil {
+		return err
+	}
+
+	// If -n is passed we'll show what would change instead of restoring
+	if options.Preview {
+		data, err := backup.Read()
+		if err != nil {
+			return err
+		}
+		PrintDiff(options, hostfile.Path, backup.Path, hostfile.GetData(), data)
+		return nil
+	}
+
+	if err := BackupHostfile(hostfile); err != nil {
+		return err
+	}
+
+	if err := hostfile.Restore(backup); err != nil {
+		return fmt.Errorf("Unable to write to %s. (error: %s)", hostfile.Path, err)
+	}
+	PruneBackups(hostfile)
+
+	fmt.Printf("Restored %s from backup %s\n", hostfile.Path, backup.ID)
+	return nil
+}
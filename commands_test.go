@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestStrPadRight(t *testing.T) {
@@ -37,3 +38,17 @@ func TestLoadHostfile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFormatTTL(t *testing.T) {
+	cases := map[time.Duration]string{
+		30 * time.Second:               "30s",
+		5*time.Minute + 10*time.Second: "5m",
+		2*time.Hour - time.Second:      "1h59m",
+		72*time.Hour + time.Hour:       "3d",
+	}
+	for duration, expected := range cases {
+		if output := FormatTTL(duration); output != expected {
+			t.Errorf("FormatTTL(%s): expected %q, found %q", duration, expected, output)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package hostess
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hostess keeps extra information about an entry, like when it expires, in
+// an annotation at the end of the entry's inline comment:
+//
+//	10.0.0.5 devbox # dev box [expires=2026-11-01T00:00:00Z owner=alice]
+//
+// The annotation is a list of key=value pairs in square brackets. Values that
+// contain spaces or other special characters are quoted like Go strings.
+
+// splitAnnotation separates an inline comment into free text and the
+// key=value pairs in its annotation. If the comment doesn't end with a valid
+// annotation, fields is nil and text is the whole comment.
+func splitAnnotation(comment string) (text string, fields map[string]string) {
+	comment = TrimWS(comment)
+	if !strings.HasSuffix(comment, "]") {
+		return comment, nil
+	}
+
+	for index := 0; index < len(comment); index++ {
+		if comment[index] != '[' || (index > 0 && comment[index-1] != ' ') {
+			continue
+		}
+		if fields, ok := parseAnnotationFields(comment[index+1 : len(comment)-1]); ok {
+			return TrimWS(comment[:index]), fields
+		}
+	}
+	return comment, nil
+}
+
+// parseAnnotationFields parses key=value pairs separated by spaces
+func parseAnnotationFields(s string) (map[string]string, bool) {
+	fields := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			break
+		}
+
+		equals := strings.Index(s, "=")
+		if equals < 1 || !isAnnotationKey(s[:equals]) {
+			return nil, false
+		}
+		key := s[:equals]
+		s = s[equals+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := quotedLength(s)
+			if end == -1 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(s[:end])
+			if err != nil {
+				return nil, false
+			}
+			value, s = unquoted, s[end:]
+			if s != "" && s[0] != ' ' {
+				return nil, false
+			}
+		} else {
+			end := strings.Index(s, " ")
+			if end == -1 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+			if strings.ContainsAny(value, `"[]`) {
+				return nil, false
+			}
+		}
+		fields[key] = value
+	}
+	return fields, len(fields) > 0
+}
+
+// quotedLength returns the length of the quoted string at the start of s,
+// including the quotes, or -1 if the closing quote is missing.
+func quotedLength(s string) int {
+	for index := 1; index < len(s); index++ {
+		switch s[index] {
+		case '\\':
+			index++
+		case '"':
+			return index + 1
+		}
+	}
+	return -1
+}
+
+func isAnnotationKey(key string) bool {
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return key != ""
+}
+
+// formatAnnotation formats fields as an annotation, sorted by key so the
+// output is stable. If there are no fields it returns a blank string.
+func formatAnnotation(fields map[string]string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := fields[key]
+		if value == "" || strings.ContainsAny(value, " \t\"[]#\\") || !strconv.CanBackquote(value) {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return "[" + strings.Join(pairs, " ") + "]"
+}
+
+// ValidateComment checks that comment can be saved in an inline comment. It
+// must fit on one line, and must not end with something that looks like an
+// annotation, e.g. [id=42], since we'd read that back as metadata.
+func ValidateComment(comment string) error {
+	if strings.ContainsAny(comment, "\r\n") {
+		return errors.New("comment must not contain line breaks")
+	}
+	if _, fields := splitAnnotation(comment); fields != nil {
+		return fmt.Errorf("comment %q must not end with [key=value]; use metadata instead", comment)
+	}
+	return nil
+}
+
+// ValidateMetadata checks that metadata can be saved in an inline comment.
+// Keys may contain letters, digits, dots, hyphens, and underscores, and
+// values must fit on one line. The expires key is reserved for
+// Hostname.Expires.
+func ValidateMetadata(metadata map[string]string) error {
+	for key, value := range metadata {
+		if !isAnnotationKey(key) {
+			return fmt.Errorf("metadata key %q may only contain letters, digits, dots, hyphens, and underscores", key)
+		}
+		if key == expiresKey {
+			return fmt.Errorf("metadata key %q is reserved; use the expiry time instead", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("metadata value for %s must not contain line breaks", key)
+		}
+	}
+	return nil
+}
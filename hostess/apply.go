@@ -0,0 +1,199 @@
+package hostess
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Entries in the JSON input to Apply and Sync may have a "state". Entries
+// that are present (the default) are added to the Hostlist, and entries that
+// are absent are removed from it:
+//
+//	[
+//	  {"domain": "api.local", "ip": "10.0.0.7", "enabled": true},
+//	  {"domain": "old.local", "state": "absent"}
+//	]
+//
+// An absent entry with an IP only removes that IP, and one with a profile only
+// removes entries in that profile.
+const (
+	StatePresent = "present"
+	StateAbsent  = "absent"
+)
+
+// ErrPruneWithoutBlock is returned when pruning a Hostfile that doesn't use a
+// managed block. Without one, pruning would remove entries that hostess
+// doesn't manage, like the machine's own hostname.
+var ErrPruneWithoutBlock = fmt.Errorf("pruning only works with a managed block; set %s", EnvHostessBlock)
+
+// loopbackDomains are never removed by Sync, since the system needs them
+var loopbackDomains = map[string]bool{
+	"localhost":     true,
+	"ip6-localhost": true,
+	"ip6-loopback":  true,
+	"broadcasthost": true,
+}
+
+// absentEntry is an entry in the JSON input with "state": "absent"
+type absentEntry struct {
+	Domain  string
+	Profile string
+	// Hostname is nil if the entry does not have an IP
+	Hostname *Hostname
+}
+
+// matches returns true if hostname should be removed
+func (a *absentEntry) matches(hostname *Hostname) bool {
+	if !hostname.EqualDomain(a.Domain) {
+		return false
+	}
+	if a.Profile != "" && hostname.Profile != a.Profile {
+		return false
+	}
+	return a.Hostname == nil || a.Hostname.Equal(hostname)
+}
+
+// decodeApply parses the JSON input to Apply and Sync into the Hostnames that
+// should be present and the entries that should be absent.
+func decodeApply(jsonbytes []byte) (Hostlist, []*absentEntry, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(jsonbytes, &entries); err != nil {
+		return nil, nil, err
+	}
+
+	present := Hostlist{}
+	absent := []*absentEntry{}
+	for _, entry := range entries {
+		var header struct {
+			Domain string `json:"domain"`
+			State  string `json:"state"`
+		}
+		if err := json.Unmarshal(entry, &header); err != nil {
+			return nil, nil, err
+		}
+
+		switch header.State {
+		case "", StatePresent:
+			hostname := &Hostname{}
+			if err := json.Unmarshal(entry, hostname); err != nil {
+				return nil, nil, err
+			}
+			present = append(present, hostname)
+
+		case StateAbsent:
+			var decoded hostnameJSON
+			if err := json.Unmarshal(entry, &decoded); err != nil {
+				return nil, nil, err
+			}
+			removal := &absentEntry{Domain: decoded.Domain, Profile: decoded.Profile}
+			if decoded.IP != "" {
+				// We're removing the entry, so it doesn't need to be a valid
+				// name, only one that might be in the hosts file
+				hostname, err := newHostname(decoded.Domain, decoded.IP, false, true)
+				if err != nil {
+					return nil, nil, err
+				}
+				removal.Hostname = hostname
+			} else if decoded.Domain == "" {
+				return nil, nil, fmt.Errorf("absent entry is missing a domain")
+			}
+			absent = append(absent, removal)
+
+		default:
+			return nil, nil, fmt.Errorf("invalid state %q for %s; expected %s or %s", header.State, header.Domain, StatePresent, StateAbsent)
+		}
+	}
+	return present, absent, nil
+}
+
+// checkNewNames returns an error if a Hostname in hostnames is not already in
+// the Hostlist and NewHostname would reject its domain. JSON is decoded
+// leniently so the output of dump can be applied to the hosts file it came
+// from, but new names have to be valid.
+func (h *Hostlist) checkNewNames(hostnames Hostlist) error {
+	for _, hostname := range hostnames {
+		if h.ContainsDomain(hostname.Domain) {
+			continue
+		}
+		if _, err := normalizeDomain(hostname.Domain, IsLenient()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAbsent removes the Hostnames matching the absent entries, and returns
+// the ones it removed.
+func (h *Hostlist) removeAbsent(absent []*absentEntry) Hostlist {
+	kept := Hostlist{}
+	removed := Hostlist{}
+	for _, hostname := range *h {
+		matched := false
+		for _, removal := range absent {
+			if removal.matches(hostname) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			removed = append(removed, hostname)
+		} else {
+			kept = append(kept, hostname)
+		}
+	}
+	*h = kept
+	return removed
+}
+
+// Sync makes the Hostlist match the JSON input exactly: entries that are
+// present in the input replace the ones we have, and everything else is
+// removed, except for loopback entries like localhost. Returns the Hostnames
+// it removed.
+//
+// Sync removes everything that isn't listed, so only use it on the Hostnames
+// in a managed block (see Hostfile.Block). Otherwise it would remove entries
+// hostess doesn't manage, like the machine's own hostname.
+func (h *Hostlist) Sync(jsonbytes []byte) (Hostlist, error) {
+	present, absent, err := decodeApply(jsonbytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkNewNames(present); err != nil {
+		return nil, err
+	}
+	return h.sync(present, absent), nil
+}
+
+// SyncHostnames is like Sync, but takes the Hostnames that should be present
+// instead of JSON, e.g. from ParseCSV.
+func (h *Hostlist) SyncHostnames(hostnames Hostlist) Hostlist {
+	return h.sync(hostnames, nil)
+}
+
+// sync makes the Hostlist match present, and removes the absent entries.
+// Returns the Hostnames it removed.
+func (h *Hostlist) sync(present Hostlist, absent []*absentEntry) Hostlist {
+	wanted := map[string]bool{}
+	for _, hostname := range present {
+		wanted[hostnameKey(hostname)] = true
+	}
+
+	synced := Hostlist{}
+	removed := Hostlist{}
+	for _, hostname := range *h {
+		switch {
+		case wanted[hostnameKey(hostname)]:
+			// Replaced below
+		case loopbackDomains[DomainKey(hostname.Domain)]:
+			synced = append(synced, hostname)
+		default:
+			removed = append(removed, hostname)
+		}
+	}
+	for _, hostname := range present {
+		synced.Add(hostname)
+	}
+	*h = synced
+
+	return append(removed, h.removeAbsent(absent)...)
+}
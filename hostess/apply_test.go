@@ -0,0 +1,93 @@
+package hostess_test
+
+import (
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestApplyAbsent(t *testing.T) {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("old.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("old.local", "::1", true))
+	hosts.Add(hostess.MustHostname("v6.local", "10.0.0.2", true))
+	hosts.Add(hostess.MustHostname("v6.local", "::2", true))
+
+	const input = `[
+  {"domain": "new.local", "ip": "10.0.0.3", "enabled": true},
+  {"domain": "old.local", "state": "absent"},
+  {"domain": "v6.local", "ip": "::2", "state": "absent"}
+]`
+	if err := hosts.Apply([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "10.0.0.2 v6.local\n10.0.0.3 new.local\n"
+	if output := string(hosts.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	if err := hosts.Apply([]byte(`[{"domain": "a.local", "state": "gone"}]`)); err == nil {
+		t.Error("Expected an error for an invalid state")
+	}
+}
+
+func TestSync(t *testing.T) {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("localhost", "127.0.0.1", true))
+	hosts.Add(hostess.MustHostname("api.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("db.local", "10.0.0.2", true))
+	hosts.Add(hostess.MustHostname("stale.local", "10.0.0.3", true))
+
+	// api.local changes, db.local is disabled, and stale.local is not listed
+	const input = `[
+  {"domain": "api.local", "ip": "10.0.0.4", "enabled": true},
+  {"domain": "db.local", "ip": "10.0.0.2", "enabled": false},
+  {"domain": "web.local", "ip": "10.0.0.5", "enabled": true}
+]`
+	removed, err := hosts.Sync([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Domain != "stale.local" {
+		t.Errorf("Expected stale.local to be removed, found %v", removed)
+	}
+
+	// localhost is kept even though it's not in the input
+	expected := `127.0.0.1 localhost
+# 10.0.0.2 db.local
+10.0.0.4 api.local
+10.0.0.5 web.local
+`
+	if output := string(hosts.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestApplyLegacyNames(t *testing.T) {
+	// my_host is already in the hosts file, so JSON from dump can include it
+	hosts := hostess.MustParseLine("10.0.0.1 my_host")
+	dump, err := hosts.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Apply(dump); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Apply([]byte(`[{"domain": "my_host", "ip": "10.0.0.2", "enabled": true}]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// New names still have to be valid
+	if err := hosts.Apply([]byte(`[{"domain": "other_host", "ip": "10.0.0.3", "enabled": true}]`)); err == nil {
+		t.Error("Expected an error applying other_host")
+	}
+	if _, err := hosts.Sync([]byte(`[{"domain": "other_host", "ip": "10.0.0.3", "enabled": true}]`)); err == nil {
+		t.Error("Expected an error syncing other_host")
+	}
+
+	expected := "10.0.0.2 my_host\n"
+	if output := string(hosts.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
@@ -0,0 +1,206 @@
+package hostess
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const EnvHostessBackupDir = `HOSTESS_BACKUP_DIR`
+const EnvHostessBackups = `HOSTESS_BACKUPS`
+
+// DefaultBackups is the number of backups we keep if HOSTESS_BACKUPS is not
+// set.
+const DefaultBackups = 5
+
+// backupTimeFormat is used for backup IDs. IDs sort in the same order as the
+// times they represent.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// ErrBackupNotFound is returned when there is no backup matching an ID.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup is a copy of the hosts file taken before hostess changed it.
+type Backup struct {
+	ID   string
+	Path string
+	Time time.Time
+}
+
+// GetBackupDir returns the directory where backups are stored; either env
+// HOSTESS_BACKUP_DIR or the directory containing the hosts file.
+func GetBackupDir() string {
+	dir := os.Getenv(EnvHostessBackupDir)
+	if dir == "" {
+		dir = filepath.Dir(GetHostsPath())
+	}
+	return dir
+}
+
+// GetBackupCount returns the number of backups to keep; either env
+// HOSTESS_BACKUPS or DefaultBackups. Zero disables backups.
+func GetBackupCount() (int, error) {
+	value := os.Getenv(EnvHostessBackups)
+	if value == "" {
+		return DefaultBackups, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("%s must be a number 0 or greater, found %q", EnvHostessBackups, value)
+	}
+	return count, nil
+}
+
+// backupPrefix is the filename prefix for backups of the hosts file at path.
+func backupPrefix(path string) string {
+	return filepath.Base(path) + ".hostess-backup."
+}
+
+// ListBackups returns backups of the hosts file at path found in dir, newest
+// first.
+func ListBackups(dir, path string) ([]*Backup, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	prefix := backupPrefix(path)
+	backups := []*Backup{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(file.Name(), prefix)
+		created, err := time.Parse(backupTimeFormat, id)
+		if err != nil {
+			// Not one of ours
+			continue
+		}
+		backups = append(backups, &Backup{
+			ID:   id,
+			Path: filepath.Join(dir, file.Name()),
+			Time: created,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+
+	return backups, nil
+}
+
+// FindBackup returns the backup of the hosts file at path whose ID starts
+// with id. If id is blank, the newest backup is returned. If no backup (or
+// more than one backup) matches, FindBackup returns an error.
+func FindBackup(dir, path, id string) (*Backup, error) {
+	backups, err := ListBackups(dir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*Backup
+	for _, backup := range backups {
+		if strings.HasPrefix(backup.ID, id) {
+			found = append(found, backup)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, ErrBackupNotFound
+	}
+	if id != "" && len(found) > 1 {
+		return nil, fmt.Errorf("backup ID %q is ambiguous; it matches %d backups", id, len(found))
+	}
+	return found[0], nil
+}
+
+// Read returns the contents of the backup.
+func (b *Backup) Read() ([]byte, error) {
+	return ioutil.ReadFile(b.Path)
+}
+
+// Backup saves a copy of the hosts file as it was when we read it into dir.
+// If keep is zero or we never read anything, Backup does nothing and returns
+// nil. Backup doesn't delete old backups, so we still have them if the save
+// fails; call PruneBackups after the hosts file has been written.
+func (h *Hostfile) Backup(dir string, keep int) (*Backup, error) {
+	if keep <= 0 || len(h.data) == 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return createBackup(dir, h.Path, h.data)
+}
+
+// PruneBackups deletes the oldest backups of the hosts file at path in dir so
+// at most keep remain. If keep is zero backups are disabled, so PruneBackups
+// leaves any existing backups alone.
+func PruneBackups(dir, path string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(dir, path)
+	if err != nil {
+		return err
+	}
+	for index := keep; index < len(backups); index++ {
+		if err := os.Remove(backups[index].Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createBackup writes data to a new backup file in dir. If the clock is too
+// coarse to give us a unique ID we nudge the time forward until we find one.
+func createBackup(dir, path string, data []byte) (*Backup, error) {
+	created := time.Now().UTC()
+	for {
+		id := created.Format(backupTimeFormat)
+		backup := &Backup{
+			ID:   id,
+			Path: filepath.Join(dir, backupPrefix(path)+id),
+			Time: created,
+		}
+
+		file, err := os.OpenFile(backup.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			created = created.Add(time.Nanosecond)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return backup, file.Close()
+	}
+}
+
+// Restore replaces the hosts file with the contents of backup. The Hostfile
+// itself is not changed, so you should reload it if you want to keep using it.
+func (h *Hostfile) Restore(backup *Backup) error {
+	data, err := backup.Read()
+	if err != nil {
+		return err
+	}
+	return writeHostsFile(h.Path, data)
+}
@@ -0,0 +1,97 @@
+package hostess_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestBackupRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostess-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = filepath.Join("testdata", "hostfile1")
+	if err := hostfile.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	var newest *hostess.Backup
+	for i := 0; i < 4; i++ {
+		newest, err = hostfile.Backup(dir, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Old backups are only deleted once the hosts file has been saved
+	backups, err := hostess.ListBackups(dir, hostfile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 4 {
+		t.Fatalf("Expected 4 backups before pruning, found %d", len(backups))
+	}
+
+	if err := hostess.PruneBackups(dir, hostfile.Path, 3); err != nil {
+		t.Fatal(err)
+	}
+	backups, err = hostess.ListBackups(dir, hostfile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("Expected 3 backups, found %d", len(backups))
+	}
+	if backups[0].ID != newest.ID {
+		t.Errorf("Expected newest backup %s first, found %s", newest.ID, backups[0].ID)
+	}
+
+	found, err := hostess.FindBackup(dir, hostfile.Path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != newest.ID {
+		t.Errorf("Expected to find newest backup %s, found %s", newest.ID, found.ID)
+	}
+
+	if _, err := hostess.FindBackup(dir, hostfile.Path, "nope"); err != hostess.ErrBackupNotFound {
+		t.Errorf("Expected %q, found %v", hostess.ErrBackupNotFound, err)
+	}
+
+	data, err := found.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(hostfile.GetData()) {
+		t.Error(Diff(string(hostfile.GetData()), string(data)))
+	}
+}
+
+func TestBackupDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostess-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = filepath.Join("testdata", "hostfile1")
+	if err := hostfile.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := hostfile.Backup(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != nil {
+		t.Errorf("Expected no backup, found %s", backup.Path)
+	}
+}
@@ -0,0 +1,140 @@
+package hostess
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Zone is a DNS zone file, e.g. for BIND
+type Zone struct {
+	// Name is the zone's origin without the trailing dot, e.g. example.internal
+	// or 0.0.10.in-addr.arpa
+	Name string
+	Data []byte
+}
+
+// zoneHeader returns the $ORIGIN, SOA, and NS records for a zone. The SOA and
+// NS records are stubs pointing at ns.<origin>, so change them to match your
+// DNS server. See nsStub.
+func zoneHeader(name, origin string, serial uint32) string {
+	return fmt.Sprintf(`$ORIGIN %s
+$TTL 3600
+@	IN	SOA	ns.%s hostmaster.%s (
+		%d	; serial
+		3600	; refresh
+		900	; retry
+		604800	; expire
+		3600 )	; minimum
+@	IN	NS	ns.%s
+`, fqdn(name), fqdn(origin), fqdn(origin), serial, fqdn(origin))
+}
+
+// nsStub is the address record for the stub NS record in zoneHeader. The zone
+// won't load without an address for an in-zone name server, so if the
+// Hostlist doesn't have one we add this. Change it to your DNS server's
+// address.
+const nsStub = "ns\tIN\tA\t127.0.0.1\t; stub, change to your DNS server's address\n"
+
+// relativeName returns domain relative to origin: @ for the origin itself, or
+// a relative name for a domain under the origin. ok is false if domain is not
+// in the zone.
+func relativeName(domain, origin string) (name string, ok bool) {
+	key, originKey := DomainKey(domain), DomainKey(origin)
+	name = strings.TrimSuffix(domain, ".")
+	switch {
+	case key == originKey:
+		return "@", true
+	case strings.HasSuffix(key, "."+originKey):
+		return name[:len(name)-len(originKey)-1], true
+	}
+	return "", false
+}
+
+// reverseZone returns the name of the reverse zone for ip and the name of its
+// PTR record in that zone. IPv4 addresses are in /24 zones like
+// 0.0.10.in-addr.arpa, and IPv6 addresses are in /64 zones under ip6.arpa.
+func reverseZone(ip net.IP) (zone, record string) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.in-addr.arpa", ip4[2], ip4[1], ip4[0]), fmt.Sprintf("%d", ip4[3])
+	}
+
+	ip16 := ip.To16()
+	nibbles := make([]string, 0, 32)
+	for index := len(ip16) - 1; index >= 0; index-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x", ip16[index]&0x0f), fmt.Sprintf("%x", ip16[index]>>4))
+	}
+	return strings.Join(nibbles[16:], ".") + ".ip6.arpa", strings.Join(nibbles[:16], ".")
+}
+
+// BindZones exports the Hostlist as RFC 1035 zone files for an authoritative
+// DNS server like BIND. The first zone is the forward zone for origin, with A
+// and AAAA records for the domains under the origin, using relative names.
+// Domains outside the origin (like localhost) are left out, since BIND would
+// ignore them. It is followed by reverse zones with PTR records for each IP
+// in the forward zone, using the first domain for the IP the way a hosts file
+// does.
+//
+// serial is the SOA serial for every zone. Secondary servers only transfer a
+// zone when its serial goes up, so use a larger one each time you export, like
+// the Unix time.
+func (h *Hostlist) BindZones(origin string, serial uint32) ([]*Zone, error) {
+	origin, err := normalizeDomain(origin, IsLenient())
+	if err != nil {
+		return nil, err
+	}
+	origin = DomainKey(origin)
+
+	records := bytes.Buffer{}
+	hasNS := false
+	for _, domain := range h.byDomain() {
+		name, ok := relativeName(domain.Domain, origin)
+		if !ok {
+			continue
+		}
+		hasNS = hasNS || name == "ns"
+		for _, ip := range domain.IPv4 {
+			records.WriteString(fmt.Sprintf("%s\tIN\tA\t%s\n", name, ip))
+		}
+		for _, ip := range domain.IPv6 {
+			records.WriteString(fmt.Sprintf("%s\tIN\tAAAA\t%s\n", name, ip))
+		}
+	}
+	forward := bytes.Buffer{}
+	forward.WriteString(zoneHeader(origin, origin, serial))
+	if !hasNS {
+		forward.WriteString(nsStub)
+	}
+	forward.Write(records.Bytes())
+	zones := []*Zone{{Name: origin, Data: forward.Bytes()}}
+
+	inZone := Hostlist{}
+	first := map[string]string{}
+	for _, hostname := range h.exported() {
+		if _, ok := relativeName(hostname.Domain, origin); !ok {
+			continue
+		}
+		inZone = append(inZone, hostname)
+		if _, ok := first[hostname.IP.String()]; !ok {
+			first[hostname.IP.String()] = hostname.Domain
+		}
+	}
+
+	reverse := map[string]*bytes.Buffer{}
+	for _, ip := range inZone.GetUniqueIPs() {
+		zone, record := reverseZone(ip)
+		data, ok := reverse[zone]
+		if !ok {
+			data = bytes.NewBufferString(zoneHeader(zone, origin, serial))
+			reverse[zone] = data
+			zones = append(zones, &Zone{Name: zone})
+		}
+		data.WriteString(fmt.Sprintf("%s\tIN\tPTR\t%s\n", record, fqdn(first[ip.String()])))
+	}
+	for _, zone := range zones[1:] {
+		zone.Data = reverse[zone.Name].Bytes()
+	}
+
+	return zones, nil
+}
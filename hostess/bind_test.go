@@ -0,0 +1,77 @@
+package hostess_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+const zoneHeader = `$TTL 3600
+@	IN	SOA	ns.example.internal. hostmaster.example.internal. (
+		2026101701	; serial
+		3600	; refresh
+		900	; retry
+		604800	; expire
+		3600 )	; minimum
+@	IN	NS	ns.example.internal.
+`
+
+func TestBindZones(t *testing.T) {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("api.example.internal", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("www.example.internal", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("api.example.internal", "fd00::1", true))
+	hosts.Add(hostess.MustHostname("example.internal", "10.0.1.5", true))
+	hosts.Add(hostess.MustHostname("other.local", "10.0.0.2", true))
+	hosts.Add(hostess.MustHostname("localhost", "127.0.0.1", true))
+	hosts.Add(hostess.MustHostname("off.example.internal", "10.0.0.3", false))
+
+	zones, err := hosts.BindZones("Example.Internal.", 2026101701)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*hostess.Zone{
+		// other.local is not in the zone, so it's left out of the forward and
+		// reverse zones
+		{Name: "example.internal", Data: []byte("$ORIGIN example.internal.\n" + zoneHeader + `ns	IN	A	127.0.0.1	; stub, change to your DNS server's address
+api	IN	A	10.0.0.1
+api	IN	AAAA	fd00::1
+www	IN	A	10.0.0.1
+@	IN	A	10.0.1.5
+`)},
+		{Name: "0.0.10.in-addr.arpa", Data: []byte("$ORIGIN 0.0.10.in-addr.arpa.\n" + zoneHeader + `1	IN	PTR	api.example.internal.
+`)},
+		{Name: "1.0.10.in-addr.arpa", Data: []byte("$ORIGIN 1.0.10.in-addr.arpa.\n" + zoneHeader + `5	IN	PTR	example.internal.
+`)},
+		{Name: "0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa", Data: []byte("$ORIGIN 0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.\n" + zoneHeader + `1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0	IN	PTR	api.example.internal.
+`)},
+	}
+
+	if len(zones) != len(expected) {
+		t.Fatalf("Expected %d zones, found %d", len(expected), len(zones))
+	}
+	for index, zone := range zones {
+		if zone.Name != expected[index].Name {
+			t.Errorf("Expected zone %s, found %s", expected[index].Name, zone.Name)
+		}
+		if string(zone.Data) != string(expected[index].Data) {
+			t.Error(Diff(string(expected[index].Data), string(zone.Data)))
+		}
+	}
+
+	if _, err := hosts.BindZones("not valid", 2026101701); err == nil {
+		t.Error("Expected an error for an invalid origin")
+	}
+
+	// If the name server is in the Hostlist we don't need the stub
+	hosts.Add(hostess.MustHostname("ns.example.internal", "10.0.0.53", true))
+	zones, err = hosts.BindZones("example.internal", 2026101701)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := string(zones[0].Data); strings.Contains(data, "stub") || !strings.Contains(data, "ns\tIN\tA\t10.0.0.53\n") {
+		t.Errorf("Expected ns.example.internal instead of the stub, found:\n%s", data)
+	}
+}
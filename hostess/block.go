@@ -0,0 +1,131 @@
+package hostess
+
+import (
+	"bytes"
+	"os"
+	"strings"
+)
+
+const EnvHostessBlock = `HOSTESS_BLOCK`
+
+// GetBlockName returns the name of the managed block from env HOSTESS_BLOCK,
+// or a blank string if hostess should manage the entire hosts file.
+func GetBlockName() string {
+	return os.Getenv(EnvHostessBlock)
+}
+
+// BlockBegin returns the comment that marks the start of a managed block,
+// e.g. # BEGIN hostess
+func BlockBegin(name string) string {
+	return "# BEGIN " + name
+}
+
+// BlockEnd returns the comment that marks the end of a managed block, e.g.
+// # END hostess
+func BlockEnd(name string) string {
+	return "# END " + name
+}
+
+// managedBlock is the part of a hosts file that hostess is allowed to change.
+// When hostess manages the whole file, body is the whole file.
+type managedBlock struct {
+	// before is everything up to and including the BEGIN marker, and after
+	// is everything from the END marker on. These are written back exactly as
+	// they were read.
+	before []byte
+	body   []byte
+	after  []byte
+	// offset is the number of lines before body, so we can report the right
+	// line numbers for problems inside the block.
+	offset int
+	// found is false if the hosts file does not have a managed block yet.
+	found bool
+}
+
+// findBlock splits data into the managed block named name and everything
+// around it. If name is blank the whole file is managed. findBlock returns a
+// *MalformedLineError if the block markers are missing or repeated.
+func findBlock(path string, data []byte, name string) (*managedBlock, error) {
+	if name == "" {
+		return &managedBlock{body: data, found: true}, nil
+	}
+
+	malformed := func(index int, text, reason string) error {
+		return &MalformedLineError{Path: path, Line: index + 1, Text: TrimWS(text), Reason: reason}
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	begin, end := -1, -1
+	for index, line := range lines {
+		switch TrimWS(line) {
+		case BlockBegin(name):
+			if begin > -1 {
+				return nil, malformed(index, line, "found more than one managed block")
+			}
+			begin = index
+		case BlockEnd(name):
+			if begin == -1 || end > -1 {
+				return nil, malformed(index, line, "found the end of a managed block without the beginning")
+			}
+			end = index
+		}
+	}
+
+	if begin == -1 {
+		return &managedBlock{before: data}, nil
+	}
+	if end == -1 {
+		return nil, malformed(begin, lines[begin], "found the beginning of a managed block without the end")
+	}
+
+	return &managedBlock{
+		before: []byte(strings.Join(lines[:begin+1], "")),
+		body:   []byte(strings.Join(lines[begin+1:end], "")),
+		after:  []byte(strings.Join(lines[end:], "")),
+		offset: begin + 1,
+		found:  true,
+	}, nil
+}
+
+// UnmanagedHosts returns the entries outside the managed block, which hostess
+// reads but never changes. If Block is not set (or the managed block could not
+// be found) there are none. Problems parsing these entries are ignored, since
+// they are not ours to fix.
+func (h *Hostfile) UnmanagedHosts() Hostlist {
+	hosts := Hostlist{}
+	if h.Block == "" || h.block == nil {
+		return hosts
+	}
+	parseData(h.Path, h.block.before, 0, &hosts)
+	parseData(h.Path, h.block.after, 0, &hosts)
+	hosts.Sort()
+	return hosts
+}
+
+// wrap puts body back in between the bytes that surround the block. If the
+// hosts file does not have a managed block yet, one is added at the end, but
+// only if there is something to put in it.
+func (b *managedBlock) wrap(name string, body []byte) []byte {
+	if name == "" {
+		return body
+	}
+
+	out := bytes.Buffer{}
+	out.Write(b.before)
+	if b.found {
+		out.Write(body)
+		out.Write(b.after)
+		return out.Bytes()
+	}
+
+	if len(body) == 0 {
+		return out.Bytes()
+	}
+	if len(b.before) > 0 && !bytes.HasSuffix(b.before, []byte("\n")) {
+		out.WriteString("\n")
+	}
+	out.WriteString(BlockBegin(name) + "\n")
+	out.Write(body)
+	out.WriteString(BlockEnd(name) + "\n")
+	return out.Bytes()
+}
@@ -0,0 +1,191 @@
+package hostess_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+const blockHostfile = `127.0.0.1	localhost
+# Added by Docker Desktop
+192.168.1.20   host.docker.internal
+
+# BEGIN hostess
+10.0.0.5 devbox
+10.0.0.6 testbox # QA
+# END hostess
+10.8.0.1	vpn.corp   vpn
+`
+
+func TestManagedBlock(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+
+	hostfile := LoadTempHostfile(t, blockHostfile)
+	defer os.Remove(hostfile.Path)
+
+	// Only the entries inside the block are ours
+	if len(hostfile.Hosts) != 2 || !hostfile.Hosts.ContainsDomain("devbox") || hostfile.Hosts.ContainsDomain("vpn.corp") {
+		t.Fatalf("Expected devbox and testbox, found %v", hostfile.Hosts)
+	}
+
+	// We can still read the rest of the file
+	unmanaged := hostfile.UnmanagedHosts()
+	if len(unmanaged) != 4 || !unmanaged.ContainsDomain("vpn.corp") || unmanaged.ContainsDomain("devbox") {
+		t.Errorf("Expected the entries outside the block, found %v", unmanaged)
+	}
+
+	hostfile.Hosts.RemoveDomain("devbox")
+	hostfile.Hosts.Add(hostess.MustHostname("api.local", "10.0.0.7", true))
+	// Removing something outside the block does nothing
+	hostfile.Hosts.RemoveDomain("vpn.corp")
+
+	expected := `127.0.0.1	localhost
+# Added by Docker Desktop
+192.168.1.20   host.docker.internal
+
+# BEGIN hostess
+10.0.0.6 testbox # QA
+10.0.0.7 api.local
+# END hostess
+10.8.0.1	vpn.corp   vpn
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	// fmt only rewrites the block
+	hostfile.DiscardLayout()
+	expected = `127.0.0.1	localhost
+# Added by Docker Desktop
+192.168.1.20   host.docker.internal
+
+# BEGIN hostess
+10.0.0.6 testbox # QA
+10.0.0.7 api.local
+# END hostess
+10.8.0.1	vpn.corp   vpn
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestManagedBlockAdded(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "dev")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+
+	const data = "127.0.0.1 localhost\n10.8.0.1 vpn.corp"
+	hostfile := LoadTempHostfile(t, data)
+	defer os.Remove(hostfile.Path)
+
+	if len(hostfile.Hosts) != 0 {
+		t.Fatalf("Expected no hostnames, found %v", hostfile.Hosts)
+	}
+
+	// Nothing to manage, so nothing changes
+	if output := string(hostfile.Format()); output != data {
+		t.Error(Diff(data, output))
+	}
+
+	hostfile.Hosts.Add(hostess.MustHostname("devbox", "10.0.0.5", true))
+	if err := hostfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ioutil.ReadFile(hostfile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `127.0.0.1 localhost
+10.8.0.1 vpn.corp
+# BEGIN dev
+10.0.0.5 devbox
+# END dev
+`
+	if output := string(saved); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestManagedBlockErrors(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+
+	cases := map[string]int{
+		"# BEGIN hostess\n10.0.0.5 devbox\n":                               1,
+		"10.0.0.5 devbox\n# END hostess\n":                                 2,
+		"# BEGIN hostess\n# END hostess\n# BEGIN hostess\n# END hostess\n": 3,
+	}
+
+	for data, line := range cases {
+		tempfile, err := ioutil.TempFile("", "hostess-test-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tempfile.Name())
+		if _, err := tempfile.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		tempfile.Close()
+
+		hostfile := hostess.NewHostfile()
+		hostfile.Path = tempfile.Name()
+		if err := hostfile.Read(); err != nil {
+			t.Fatal(err)
+		}
+		errs := hostfile.Parse()
+		if len(errs) != 1 {
+			t.Errorf("Expected 1 error for %q, found %v", data, errs)
+			continue
+		}
+		if e, ok := errs[0].(*hostess.MalformedLineError); !ok || e.Line != line {
+			t.Errorf("Expected malformed line %d for %q, found %#v", line, data, errs[0])
+		}
+
+		// We don't know which part of the file is ours so we won't write it
+		if err := hostfile.Save(); err == nil {
+			t.Errorf("Expected Save to fail for %q", data)
+		}
+	}
+}
+
+func TestManagedBlockExternalChanges(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+
+	hostfile := LoadTempHostfile(t, blockHostfile)
+	defer os.Remove(hostfile.Path)
+
+	hostfile.Hosts.Add(hostess.MustHostname("api.local", "10.0.0.7", true))
+
+	// The VPN client changes its entry while we're working
+	external := bytes.Replace([]byte(blockHostfile), []byte("10.8.0.1"), []byte("10.9.0.1"), 1)
+	if err := ioutil.WriteFile(hostfile.Path, external, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hostfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ioutil.ReadFile(hostfile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `127.0.0.1	localhost
+# Added by Docker Desktop
+192.168.1.20   host.docker.internal
+
+# BEGIN hostess
+10.0.0.5 devbox
+10.0.0.6 testbox # QA
+10.0.0.7 api.local
+# END hostess
+10.9.0.1	vpn.corp   vpn
+`
+	if output := string(saved); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
@@ -0,0 +1,188 @@
+package hostess
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Hosts entries can be kept in a spreadsheet and exported as CSV (or TSV),
+// with one row per Hostname:
+//
+//	domain,ip,enabled,comment
+//	api.local,10.0.0.1,true,INC-1234 bypass CDN
+//
+// The header row is optional. Without it, the columns are domain, ip, enabled,
+// and comment, in that order, and only domain and ip are required. With it,
+// the columns may be in any order and other columns are ignored.
+
+// csvColumns are the header names for each column ParseCSV understands
+var csvColumns = map[string]string{
+	"domain":   "domain",
+	"hostname": "domain",
+	"host":     "domain",
+	"ip":       "ip",
+	"address":  "ip",
+	"enabled":  "enabled",
+	"comment":  "comment",
+}
+
+// csvHeader returns the column index for each field if record is a header
+// row, or nil if it isn't
+func csvHeader(record []string) map[string]int {
+	columns := map[string]int{}
+	for index, cell := range record {
+		if column, ok := csvColumns[strings.ToLower(TrimWS(cell))]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = index
+			}
+		}
+	}
+	if _, ok := columns["domain"]; !ok {
+		return nil
+	}
+	if _, ok := columns["ip"]; !ok {
+		return nil
+	}
+	return columns
+}
+
+// csvLineReader gives csv.Reader the data one line at a time, so we can tell
+// which lines it read for each record. Row numbers from counting records would
+// be wrong, since csv.Reader skips blank lines and a quoted cell may contain a
+// newline.
+type csvLineReader struct {
+	data []byte
+	// line is the number of the line we're reading
+	line int
+	// start is the first line that wasn't blank since the last call to next,
+	// i.e. where the current record starts
+	start int
+}
+
+// Read implements io.Reader. It never returns data from more than one line.
+func (r *csvLineReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	end := bytes.IndexByte(r.data, '\n') + 1
+	if end == 0 {
+		end = len(r.data)
+	}
+	if r.start == 0 && len(bytes.TrimRight(r.data[:end], "\r\n")) > 0 {
+		r.start = r.line
+	}
+	n := copy(p, r.data[:end])
+	r.data = r.data[n:]
+	if n == end {
+		r.line++
+	}
+	return n, nil
+}
+
+// next resets start before reading the next record
+func (r *csvLineReader) next() {
+	r.start = 0
+}
+
+// parseEnabled parses the enabled column. A blank cell means enabled.
+func parseEnabled(value string) (bool, error) {
+	switch strings.ToLower(TrimWS(value)) {
+	case "", "yes", "y", "on":
+		return true, nil
+	case "no", "n", "off":
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(TrimWS(value))
+	if err != nil {
+		return false, fmt.Errorf("invalid enabled value %q; expected true or false", value)
+	}
+	return enabled, nil
+}
+
+// ParseCSV reads Hostnames from CSV data, or TSV if comma is '\t'. Errors
+// give the line the row starts on, counting the header row and blank lines,
+// so they match the row numbers in a spreadsheet.
+func ParseCSV(path string, data []byte, comma rune) (Hostlist, []error) {
+	lines := &csvLineReader{data: data, line: 1}
+	reader := csv.NewReader(lines)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = comma == '\t'
+
+	hostnames := Hostlist{}
+	errs := []error{}
+	columns := map[string]int{"domain": 0, "ip": 1, "enabled": 2, "comment": 3}
+	for index := 0; ; index++ {
+		lines.next()
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// We can't tell where the next row starts, so we stop here
+			if e, ok := err.(*csv.ParseError); ok {
+				return nil, append(errs, fmt.Errorf("%s%s", position(path, e.Line), e.Err))
+			}
+			return nil, append(errs, fmt.Errorf("%s%s", position(path, 0), err))
+		}
+		row := lines.start
+		if index == 0 {
+			if header := csvHeader(record); header != nil {
+				columns = header
+				continue
+			}
+		}
+
+		cell := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return TrimWS(record[index])
+		}
+		text := strings.Join(record, string(comma))
+		if TrimWS(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		if cell("domain") == "" || cell("ip") == "" {
+			errs = append(errs, &MalformedLineError{Path: path, Line: row, Text: text, Reason: "expected a domain and ip"})
+			continue
+		}
+		enabled, err := parseEnabled(cell("enabled"))
+		if err != nil {
+			errs = append(errs, &MalformedLineError{Path: path, Line: row, Text: text, Reason: err.Error()})
+			continue
+		}
+		hostname, err := NewHostname(cell("domain"), cell("ip"), enabled)
+		if err != nil {
+			errs = append(errs, locateError(err, path, row, text, 0))
+			continue
+		}
+		if err := ValidateComment(cell("comment")); err != nil {
+			errs = append(errs, &MalformedLineError{Path: path, Line: row, Text: text, Reason: err.Error()})
+			continue
+		}
+		hostname.Comment = cell("comment")
+		hostnames = append(hostnames, hostname)
+	}
+	return hostnames, errs
+}
+
+// DumpCSV exports all entries in the Hostlist as CSV with a header row, or as
+// TSV if comma is '\t'. See ParseCSV.
+func (h *Hostlist) DumpCSV(comma rune) ([]byte, error) {
+	out := bytes.Buffer{}
+	writer := csv.NewWriter(&out)
+	writer.Comma = comma
+	writer.Write([]string{"domain", "ip", "enabled", "comment"})
+	for _, hostname := range *h {
+		writer.Write([]string{hostname.Domain, hostname.FormatIP(), strconv.FormatBool(hostname.Enabled), hostname.Comment})
+	}
+	writer.Flush()
+	return out.Bytes(), writer.Error()
+}
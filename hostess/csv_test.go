@@ -0,0 +1,116 @@
+package hostess_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestParseCSV(t *testing.T) {
+	const data = `Comment,IP,Domain,Owner
+INC-1234 bypass CDN,10.0.0.1,api.local,ops
+"staging, temporary",fd00::1,api.local,dev
+`
+	hostnames, errs := hostess.ParseCSV("hosts.csv", []byte(data), ',')
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	expected := `10.0.0.1 api.local # INC-1234 bypass CDN
+fd00::1 api.local # staging, temporary
+`
+	if output := formatImported(hostnames); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	// Without a header the columns are domain, ip, enabled, comment
+	hostnames, errs = hostess.ParseCSV("hosts.tsv", []byte("db.local\t10.0.0.2\tno\n\nwww.local\t10.0.0.3\n"), '\t')
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	expected = `# 10.0.0.2 db.local
+10.0.0.3 www.local
+`
+	if output := formatImported(hostnames); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	const data = `domain,ip,enabled
+api.local,10.0.0.1,true
+db.local,10.0.0.256,true
+www.local,10.0.0.3,maybe
+old.local
+`
+	_, errs := hostess.ParseCSV("hosts.csv", []byte(data), ',')
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, found %v", errs)
+	}
+	// Rows are numbered like a spreadsheet, counting the header
+	if e, ok := errs[0].(*hostess.InvalidIPError); !ok || e.Line != 3 || e.Path != "hosts.csv" {
+		t.Errorf("Expected an invalid IP error on row 3, found %#v", errs[0])
+	}
+	for index, line := range []int{4, 5} {
+		if e, ok := errs[index+1].(*hostess.MalformedLineError); !ok || e.Line != line || e.Path != "hosts.csv" {
+			t.Errorf("Expected a malformed line error on row %d, found %#v", line, errs[index+1])
+		}
+	}
+}
+
+func TestParseCSVLineNumbers(t *testing.T) {
+	// Blank lines and newlines in quoted cells still count, so errors match
+	// the lines in the file
+	const data = `domain,ip,enabled,comment
+
+api.local,10.0.0.1,true,"first line
+second line"
+db.local,10.0.0.256,true,
+`
+	_, errs := hostess.ParseCSV("hosts.csv", []byte(data), ',')
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, found %v", errs)
+	}
+	// Comments can't contain newlines
+	if e, ok := errs[0].(*hostess.MalformedLineError); !ok || e.Line != 3 {
+		t.Errorf("Expected a malformed line error on line 3, found %#v", errs[0])
+	}
+	if e, ok := errs[1].(*hostess.InvalidIPError); !ok || e.Line != 5 {
+		t.Errorf("Expected an invalid IP error on line 5, found %#v", errs[1])
+	}
+
+	_, errs = hostess.ParseCSV("hosts.csv", []byte("api.local,10.0.0.1\n\ndb.local,\"10.0.0.2\n"), ',')
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "hosts.csv:3: ") {
+		t.Errorf("Expected an error on line 3, found %v", errs)
+	}
+}
+
+func TestDumpCSV(t *testing.T) {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("api.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("db.local", "10.0.0.2", false))
+	hosts.Add(hostess.MustHostname("www.local", "10.0.0.3", true))
+	(*hosts)[2].Comment = "shared, for now"
+
+	output, err := hosts.DumpCSV(',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `domain,ip,enabled,comment
+api.local,10.0.0.1,true,
+db.local,10.0.0.2,false,
+www.local,10.0.0.3,true,"shared, for now"
+`
+	if string(output) != expected {
+		t.Error(Diff(expected, string(output)))
+	}
+
+	// Round trip
+	hostnames, errs := hostess.ParseCSV("hosts.csv", output, ',')
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if formatImported(hostnames) != formatImported(*hosts) {
+		t.Error(Diff(formatImported(*hosts), formatImported(hostnames)))
+	}
+}
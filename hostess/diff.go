@@ -0,0 +1,91 @@
+package hostess
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Actions for a Change
+const (
+	ActionAdd     = "add"
+	ActionUpdate  = "update"
+	ActionEnable  = "enable"
+	ActionDisable = "disable"
+	ActionRemove  = "remove"
+)
+
+// Change is one change to a Hostlist. Before is nil when a Hostname is added,
+// and After is nil when it is removed. An update changes the IP or anything
+// else about a Hostname, while enable and disable only change whether it is
+// enabled.
+type Change struct {
+	Action string    `json:"action"`
+	Before *Hostname `json:"before,omitempty"`
+	After  *Hostname `json:"after,omitempty"`
+}
+
+// hostname returns the Hostname the change is about, for sorting
+func (c *Change) hostname() *Hostname {
+	if c.After != nil {
+		return c.After
+	}
+	return c.Before
+}
+
+// String formats the Change for people, e.g.
+// update api.local -> 10.0.0.1 (On) => 10.0.0.4 (On)
+func (c *Change) String() string {
+	switch c.Action {
+	case ActionAdd:
+		return fmt.Sprintf("%-7s %s", c.Action, c.After.FormatHuman())
+	case ActionRemove:
+		return fmt.Sprintf("%-7s %s", c.Action, c.Before.FormatHuman())
+	case ActionUpdate:
+		s := fmt.Sprintf("%-7s %s => %s %s", c.Action, c.Before.FormatHuman(), c.After.FormatIP(), c.After.FormatEnabled())
+		if comment := c.After.FormatComment(); comment != c.Before.FormatComment() {
+			s += " # " + comment
+		}
+		return s
+	}
+	return fmt.Sprintf("%-7s %s", c.Action, c.After.FormatHuman())
+}
+
+// Diff returns the Changes that turn a into b. Hostnames are matched by domain,
+// IP version, zone, and profile, so an update means the IP (or the comment,
+// metadata, or expiry time) changed. The Changes are sorted by domain and IP
+// version so the output is stable.
+func Diff(a, b Hostlist) []*Change {
+	changes := []*Change{}
+
+	bKeys := map[string]*Hostname{}
+	for _, hostname := range b {
+		bKeys[hostnameKey(hostname)] = hostname
+	}
+	aKeys := map[string]*Hostname{}
+	for _, hostname := range a {
+		key := hostnameKey(hostname)
+		aKeys[key] = hostname
+
+		updated, ok := bKeys[key]
+		switch {
+		case !ok:
+			changes = append(changes, &Change{Action: ActionRemove, Before: hostname})
+		case !updated.IP.Equal(hostname.IP) || updated.FormatComment() != hostname.FormatComment():
+			changes = append(changes, &Change{Action: ActionUpdate, Before: hostname, After: updated})
+		case updated.Enabled && !hostname.Enabled:
+			changes = append(changes, &Change{Action: ActionEnable, Before: hostname, After: updated})
+		case !updated.Enabled && hostname.Enabled:
+			changes = append(changes, &Change{Action: ActionDisable, Before: hostname, After: updated})
+		}
+	}
+	for _, hostname := range b {
+		if _, ok := aKeys[hostnameKey(hostname)]; !ok {
+			changes = append(changes, &Change{Action: ActionAdd, After: hostname})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return hostnameKey(changes[i].hostname()) < hostnameKey(changes[j].hostname())
+	})
+	return changes
+}
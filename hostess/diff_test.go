@@ -0,0 +1,55 @@
+package hostess_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestDiff(t *testing.T) {
+	a := hostess.Hostlist{
+		hostess.MustHostname("api.local", "10.0.0.1", true),
+		hostess.MustHostname("api.local", "::1", true),
+		hostess.MustHostname("db.local", "10.0.0.2", true),
+		hostess.MustHostname("old.local", "10.0.0.3", true),
+		hostess.MustHostname("web.local", "10.0.0.4", false),
+	}
+	b := hostess.Hostlist{
+		hostess.MustHostname("web.local", "10.0.0.4", true),
+		hostess.MustHostname("new.local", "10.0.0.5", true),
+		hostess.MustHostname("DB.local", "10.0.0.2", false),
+		hostess.MustHostname("api.local", "::1", true),
+		hostess.MustHostname("api.local", "10.0.0.6", true),
+	}
+
+	changes := hostess.Diff(a, b)
+	expected := []string{
+		"update  api.local -> 10.0.0.1 (On) => 10.0.0.6 (On)",
+		"disable DB.local -> 10.0.0.2 (Off)",
+		"add     new.local -> 10.0.0.5 (On)",
+		"remove  old.local -> 10.0.0.3 (On)",
+		"enable  web.local -> 10.0.0.4 (On)",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, found %v", len(expected), changes)
+	}
+	for index, change := range changes {
+		if change.String() != expected[index] {
+			t.Errorf("Expected %q, found %q", expected[index], change.String())
+		}
+	}
+
+	if changes := hostess.Diff(a, a); len(changes) != 0 {
+		t.Errorf("Expected no changes, found %v", changes)
+	}
+
+	data, err := json.Marshal(changes[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedJSON = `{"action":"remove","before":{"domain":"old.local","ip":"10.0.0.3","enabled":true}}`
+	if string(data) != expectedJSON {
+		t.Errorf("Expected %s, found %s", expectedJSON, data)
+	}
+}
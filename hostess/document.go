@@ -0,0 +1,252 @@
+package hostess
+
+import (
+	"bytes"
+	"strings"
+)
+
+// LineKind describes what a single line in a hosts file contains.
+type LineKind int
+
+const (
+	// BlankLine is empty or contains only whitespace.
+	BlankLine LineKind = iota
+	// CommentLine is a comment that does not parse as a hosts entry.
+	CommentLine
+	// EntryLine contains an IP and one or more hostnames. Disabled entries
+	// (i.e. commented out with a leading #) are also EntryLines.
+	EntryLine
+	// InvalidLine is not a comment but does not contain a valid entry. It is
+	// written back unchanged.
+	InvalidLine
+	// ProfileBeginLine starts a profile section, e.g. # BEGIN profile staging
+	ProfileBeginLine
+	// ProfileEndLine ends a profile section, e.g. # END profile staging
+	ProfileEndLine
+)
+
+// Line is a single line of a hosts file as it was read from disk. Hostfile
+// keeps a list of these so it can write comments, blank lines, and entries
+// that were not changed back out exactly as they were found.
+type Line struct {
+	Kind LineKind
+	// Raw is the original text of the line, without the trailing newline.
+	Raw string
+	// Comment is the trailing comment on an EntryLine, without the leading #
+	// or the annotation. For example, "dev box" in:
+	// 10.0.0.5 devbox # dev box [expires=2026-11-01T00:00:00Z]
+	Comment string
+	// Hostnames are the entries that were parsed from this line. These are
+	// a snapshot and are not updated when the Hostfile's Hostlist changes.
+	Hostnames Hostlist
+	// Profile is the name of the profile for a ProfileBeginLine or
+	// ProfileEndLine.
+	Profile string
+}
+
+// NewLine classifies raw, which should not include a trailing newline, and
+// parses any hosts entries it contains. If raw is not blank, a comment, or a
+// valid entry, NewLine returns an InvalidLine along with an *InvalidIPError,
+// *InvalidDomainError, or *MalformedLineError explaining what is wrong with it.
+func NewLine(raw string) (*Line, error) {
+	line := &Line{Raw: raw}
+
+	text := TrimWS(raw)
+	if text == "" {
+		line.Kind = BlankLine
+		return line, nil
+	}
+
+	if kind, profile := parseProfileMarker(text); kind != CommentLine {
+		line.Kind = kind
+		line.Profile = profile
+		return line, nil
+	}
+
+	hostnames, err := ParseLine(text)
+	if err == nil && len(hostnames) > 0 {
+		line.Kind = EntryLine
+		line.Hostnames = hostnames
+		line.Comment, _ = splitAnnotation(inlineComment(text))
+		return line, nil
+	}
+
+	// Disabled entries and comments look the same, so if a commented line
+	// doesn't parse we'll assume it's just a comment.
+	if strings.HasPrefix(text, "#") {
+		line.Kind = CommentLine
+		return line, nil
+	}
+
+	line.Kind = InvalidLine
+	if err != nil {
+		return line, err
+	}
+	// ParseLine found an IP (or something) but no hostnames
+	fields := strings.Fields(strings.Split(text, "#")[0])
+	if _, _, err := parseIP(fields[0]); err != nil {
+		return line, err
+	}
+	return line, &MalformedLineError{Reason: "expected one or more hostnames after the IP address"}
+}
+
+// inlineComment returns the text following the first # on a line, skipping
+// the leading # that marks a disabled entry.
+func inlineComment(text string) string {
+	text = strings.TrimPrefix(text, "#")
+	index := strings.Index(text, "#")
+	if index == -1 {
+		return ""
+	}
+	return TrimWS(text[index+1:])
+}
+
+// ipVersion returns 4 or 6 depending on the Hostname's IP, for use with the
+// Hostlist *V functions.
+func ipVersion(hostname *Hostname) int {
+	if hostname.IPv6 {
+		return 6
+	}
+	return 4
+}
+
+// formatLines renders lines, substituting the current contents of hosts for
+// the entries that were parsed from them. Entry lines whose hostnames are all
+// still present and unchanged are written verbatim. Entry lines where
+// something changed are regenerated in place, and lines whose hostnames were
+// all removed are dropped.
+//
+// Hostnames that do not appear on any line are added at the end of their
+// profile's section, or at the end of the file if they are not in a profile.
+// Sections for profiles that are not listed in profiles are dropped, and
+// sections for new profiles are added at the end of the file.
+func formatLines(lines []*Line, hosts Hostlist, profiles []string) []byte {
+	placed := map[*Hostname]bool{}
+
+	// First we figure out which hostnames are still on each line, so we know
+	// which ones are left over when we get to the end of each profile.
+	current := make([]Hostlist, len(lines))
+	untouched := make([]bool, len(lines))
+	for index, line := range lines {
+		if line.Kind != EntryLine {
+			continue
+		}
+		untouched[index] = true
+		for _, original := range line.Hostnames {
+			found := hosts.indexOfKey(hostnameKey(original))
+			if found == -1 {
+				untouched[index] = false
+				continue
+			}
+			hostname := hosts[found]
+			// A hostname that already appeared on an earlier line is a
+			// duplicate, so we drop it here.
+			if placed[hostname] {
+				untouched[index] = false
+				continue
+			}
+			if !sameHostname(hostname, original) {
+				untouched[index] = false
+			}
+			placed[hostname] = true
+			current[index] = append(current[index], hostname)
+		}
+	}
+
+	remaining := func(profile string) Hostlist {
+		hostnames := Hostlist{}
+		for _, hostname := range hosts {
+			if hostname.Profile == profile && !placed[hostname] {
+				placed[hostname] = true
+				hostnames = append(hostnames, hostname)
+			}
+		}
+		return hostnames
+	}
+
+	keep := map[string]bool{}
+	for _, profile := range profiles {
+		keep[profile] = true
+	}
+	written := map[string]bool{}
+
+	out := bytes.Buffer{}
+	for index, line := range lines {
+		switch {
+		case line.Kind == ProfileBeginLine || line.Kind == ProfileEndLine:
+			if !keep[line.Profile] {
+				continue
+			}
+			if line.Kind == ProfileEndLine {
+				unplaced := remaining(line.Profile)
+				out.Write(unplaced.Format())
+				written[line.Profile] = true
+			}
+			out.WriteString(line.Raw)
+			out.WriteString("\n")
+
+		case line.Kind != EntryLine || untouched[index]:
+			out.WriteString(line.Raw)
+			out.WriteString("\n")
+
+		case len(current[index]) > 0:
+			out.Write(current[index].Format())
+		}
+	}
+
+	unprofiled := remaining("")
+	out.Write(unprofiled.Format())
+	for _, profile := range profiles {
+		if !written[profile] {
+			out.Write(formatProfile(profile, remaining(profile)))
+		}
+	}
+
+	return out.Bytes()
+}
+
+// canonicalLines replaces each run of consecutive entry lines with the
+// Hostnames from hosts that were parsed from them, sorted and formatted by
+// Hostlist.Format. Comments, blank lines, and everything else stay where they
+// are, so a comment above a group of entries still describes that group.
+// Hostnames that already appeared on an earlier line are dropped.
+func canonicalLines(lines []*Line, hosts Hostlist) []*Line {
+	var canonical []*Line
+	placed := map[*Hostname]bool{}
+	run := Hostlist{}
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		// Every hostname in a run comes from the same profile section
+		profile := run[0].Profile
+		for _, raw := range strings.Split(strings.TrimSuffix(string(run.Format()), "\n"), "\n") {
+			line, _ := NewLine(raw)
+			for _, hostname := range line.Hostnames {
+				hostname.Profile = profile
+			}
+			canonical = append(canonical, line)
+		}
+		run = Hostlist{}
+	}
+
+	for _, line := range lines {
+		if line.Kind != EntryLine {
+			flush()
+			canonical = append(canonical, line)
+			continue
+		}
+		for _, original := range line.Hostnames {
+			found := hosts.indexOfKey(hostnameKey(original))
+			if found == -1 || placed[hosts[found]] {
+				continue
+			}
+			placed[hosts[found]] = true
+			run = append(run, hosts[found])
+		}
+	}
+	flush()
+
+	return canonical
+}
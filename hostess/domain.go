@@ -0,0 +1,168 @@
+package hostess
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const EnvHostessNames = `HOSTESS_NAMES`
+
+// punycodePrefix marks a label that has been converted from Unicode
+const punycodePrefix = "xn--"
+
+// ValidateDomain checks that domain is a valid hostname according to RFC 1123:
+// it is at most 253 characters long, and is made of dot-separated labels of 1
+// to 63 letters, digits, and hyphens that do not start or end with a hyphen.
+// A single trailing dot is allowed. Labels may also start with an underscore,
+// as in _dmarc.example.com. Unicode names must be converted with ToASCII
+// first. ValidateDomain returns *InvalidDomainError if domain is not valid.
+func ValidateDomain(domain string) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &InvalidDomainError{Domain: domain, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	name := strings.TrimSuffix(domain, ".")
+	if name == "" {
+		return invalid("hostname is blank")
+	}
+	if len(name) > 253 {
+		return invalid("hostname is longer than 253 characters")
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return invalid("hostname contains an empty label")
+		}
+		if len(label) > 63 {
+			return invalid("label %q is longer than 63 characters", label)
+		}
+		for i, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			case c == '-':
+				if i == 0 || i == len(label)-1 {
+					return invalid("label %q starts or ends with a hyphen", label)
+				}
+			case c == '_':
+				if i != 0 {
+					return invalid("label %q contains an underscore after the first character", label)
+				}
+			default:
+				return invalid("hostname contains invalid character %q", c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateDomainLenient only checks that domain is something that can be
+// written to a hosts file: it is not blank, and does not contain whitespace,
+// control characters, or a #. This is useful for legacy hosts files that
+// contain names ValidateDomain would reject.
+func ValidateDomainLenient(domain string) error {
+	if domain == "" {
+		return &InvalidDomainError{Domain: domain, Reason: "hostname is blank"}
+	}
+	for _, c := range domain {
+		if unicode.IsSpace(c) || unicode.IsControl(c) || c == '#' || c == utf8.RuneError {
+			return &InvalidDomainError{Domain: domain, Reason: fmt.Sprintf("hostname contains invalid character %q", c)}
+		}
+	}
+	return nil
+}
+
+// IsLenient returns true if env HOSTESS_NAMES is set to "lenient", in which
+// case NewHostname uses ValidateDomainLenient instead of ValidateDomain.
+func IsLenient() bool {
+	return os.Getenv(EnvHostessNames) == "lenient"
+}
+
+// IsStrict returns true if env HOSTESS_NAMES is set to "strict", in which case
+// names in the hosts file are checked with ValidateDomain when it is parsed.
+// Otherwise only new names are, and the hosts file is parsed with
+// ValidateDomainLenient so legacy names like my_host don't stop us from
+// reading it.
+func IsStrict() bool {
+	return os.Getenv(EnvHostessNames) == "strict"
+}
+
+// ToASCII converts any labels in domain that contain Unicode characters to
+// lowercase punycode, e.g. Bücher.example becomes xn--bcher-kva.example. ASCII
+// labels are left alone.
+func ToASCII(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycodeEncode(strings.ToLower(label))
+		if err != nil {
+			return "", &InvalidDomainError{Domain: domain, Reason: fmt.Sprintf("label %q is not valid UTF-8", label)}
+		}
+		labels[i] = punycodePrefix + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts any punycode labels in domain back to Unicode, e.g.
+// xn--bcher-kva.example becomes bücher.example. Labels that are not valid
+// punycode are left alone.
+func ToUnicode(domain string) string {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if len(label) < len(punycodePrefix) || !strings.EqualFold(label[:len(punycodePrefix)], punycodePrefix) {
+			continue
+		}
+		decoded, err := punycodeDecode(label[len(punycodePrefix):])
+		if err != nil {
+			continue
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeDomain converts domain to ASCII and validates it, using the lenient
+// rules if lenient is true.
+func normalizeDomain(domain string, lenient bool) (string, error) {
+	ascii, err := ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	if lenient {
+		err = ValidateDomainLenient(ascii)
+	} else {
+		err = ValidateDomain(ascii)
+	}
+	if err != nil {
+		// Report the name the user gave us rather than the punycode
+		err.(*InvalidDomainError).Domain = domain
+		return "", err
+	}
+	return ascii, nil
+}
+
+// DomainKey returns the canonical form of domain that hostess uses to decide
+// whether two domains are the same: lowercase, in punycode, and without a
+// trailing dot. Resolvers treat API.local, api.local, and api.local. as the
+// same name, so hostess does too. The original spelling is still used when
+// writing the hosts file.
+func DomainKey(domain string) string {
+	if ascii, err := ToASCII(domain); err == nil {
+		domain = ascii
+	}
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
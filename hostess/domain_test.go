@@ -0,0 +1,170 @@
+package hostess_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestValidateDomain(t *testing.T) {
+	valid := []string{
+		"localhost",
+		"my.example.com",
+		"my.example.com.",
+		"ip6-allnodes",
+		"3com.com",
+		"_dmarc.example.com",
+		"xn--bcher-kva.example",
+		strings.Repeat("a", 63) + ".com",
+		strings.Repeat("a.", 126) + "a",
+	}
+	for _, domain := range valid {
+		if err := hostess.ValidateDomain(domain); err != nil {
+			t.Errorf("Expected %q to be valid: %s", domain, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		".",
+		"foo bar",
+		"foo#bar",
+		"my_host",
+		"host_",
+		"-leading.example.com",
+		"trailing-.example.com",
+		"double..dot",
+		".leading.dot",
+		"bücher.example",
+		strings.Repeat("a", 64) + ".com",
+		strings.Repeat("a.", 127) + "a",
+	}
+	for _, domain := range invalid {
+		err := hostess.ValidateDomain(domain)
+		var invalidDomain *hostess.InvalidDomainError
+		if !errors.As(err, &invalidDomain) {
+			t.Errorf("Expected %q to be invalid, found %v", domain, err)
+		}
+	}
+}
+
+func TestValidateDomainLenient(t *testing.T) {
+	for _, domain := range []string{"my_host", "host_", strings.Repeat("a", 64) + ".com"} {
+		if err := hostess.ValidateDomainLenient(domain); err != nil {
+			t.Errorf("Expected %q to be valid: %s", domain, err)
+		}
+	}
+	for _, domain := range []string{"", "foo bar", "foo\tbar", "foo#bar"} {
+		if err := hostess.ValidateDomainLenient(domain); err == nil {
+			t.Errorf("Expected %q to be invalid", domain)
+		}
+	}
+}
+
+func TestNewHostnameDomain(t *testing.T) {
+	if _, err := hostess.NewHostname("my_host", "127.0.0.1", true); err == nil {
+		t.Error("Expected my_host to be rejected")
+	}
+
+	os.Setenv(hostess.EnvHostessNames, "lenient")
+	defer os.Unsetenv(hostess.EnvHostessNames)
+
+	hostname, err := hostess.NewHostname("my_host", "127.0.0.1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostname.Domain != "my_host" {
+		t.Errorf("Expected my_host, found %s", hostname.Domain)
+	}
+	if _, err := hostess.NewHostname("my host", "127.0.0.1", true); err == nil {
+		t.Error("Expected \"my host\" to be rejected even in lenient mode")
+	}
+}
+
+func TestPunycode(t *testing.T) {
+	cases := []struct {
+		Unicode string
+		ASCII   string
+	}{
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"español.com", "xn--espaol-zwa.com"},
+		{"日本語.jp", "xn--wgv71a119e.jp"},
+		{"www.ليهمابتكلموشعربي؟.test", "www.xn--egbpdaj6bu4bxfgehfvwxn.test"},
+		{"plain.example.com", "plain.example.com"},
+	}
+
+	for _, c := range cases {
+		ascii, err := hostess.ToASCII(c.Unicode)
+		if err != nil {
+			t.Errorf("ToASCII(%q): %s", c.Unicode, err)
+			continue
+		}
+		if ascii != c.ASCII {
+			t.Errorf("ToASCII(%q): expected %q, found %q", c.Unicode, c.ASCII, ascii)
+		}
+		if unicode := hostess.ToUnicode(c.ASCII); unicode != c.Unicode {
+			t.Errorf("ToUnicode(%q): expected %q, found %q", c.ASCII, c.Unicode, unicode)
+		}
+	}
+
+	// Unicode labels are lowercased, but ASCII labels are left alone
+	ascii, err := hostess.ToASCII("BÜCHER.Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ascii != "xn--bcher-kva.Example" {
+		t.Errorf("Expected xn--bcher-kva.Example, found %s", ascii)
+	}
+
+	// Garbage punycode is left alone
+	if unicode := hostess.ToUnicode("xn--!!.example"); unicode != "xn--!!.example" {
+		t.Errorf("Expected invalid punycode to be unchanged, found %s", unicode)
+	}
+
+	hostname, err := hostess.NewHostname("bücher.example", "127.0.0.1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostname.Domain != "xn--bcher-kva.example" {
+		t.Errorf("Expected NewHostname to convert to punycode, found %s", hostname.Domain)
+	}
+}
+
+func TestNewLineInvalidDomain(t *testing.T) {
+	// Names already in the hosts file are only checked leniently, so legacy
+	// names don't stop us from reading it
+	line, err := hostess.NewLine("10.0.0.1 good.local my_host")
+	if err != nil || line.Kind != hostess.EntryLine || len(line.Hostnames) != 2 {
+		t.Errorf("Expected an entry with 2 hostnames, found kind %d (error %v)", line.Kind, err)
+	}
+
+	line, err = hostess.NewLine("10.0.0.1 good.local my\x00host")
+	if line.Kind != hostess.InvalidLine {
+		t.Errorf("Expected an invalid line, found kind %d", line.Kind)
+	}
+	if _, ok := err.(*hostess.InvalidDomainError); !ok {
+		t.Errorf("Expected an invalid domain, found %#v", err)
+	}
+
+	// HOSTESS_NAMES=strict checks the hosts file with ValidateDomain
+	os.Setenv(hostess.EnvHostessNames, "strict")
+	defer os.Unsetenv(hostess.EnvHostessNames)
+
+	line, err = hostess.NewLine("10.0.0.1 good.local my_host")
+	if line.Kind != hostess.InvalidLine {
+		t.Errorf("Expected an invalid line, found kind %d", line.Kind)
+	}
+	if e, ok := err.(*hostess.InvalidDomainError); !ok || e.Domain != "my_host" {
+		t.Errorf("Expected invalid domain my_host, found %#v", err)
+	}
+
+	// Commented out lines we can't parse are just comments
+	line, err = hostess.NewLine("# 10.0.0.1 my_host")
+	if err != nil || line.Kind != hostess.CommentLine {
+		t.Errorf("Expected a comment, found kind %d (error %v)", line.Kind, err)
+	}
+}
@@ -0,0 +1,106 @@
+package hostess
+
+import (
+	"fmt"
+)
+
+// position formats the location of a problem in a hosts file, like
+// /etc/hosts:14: so the error messages look like a compiler's. If we don't
+// know where the problem is we return a blank string.
+func position(path string, line int) string {
+	switch {
+	case path != "" && line > 0:
+		return fmt.Sprintf("%s:%d: ", path, line)
+	case line > 0:
+		return fmt.Sprintf("line %d: ", line)
+	case path != "":
+		return path + ": "
+	}
+	return ""
+}
+
+// DuplicateError indicates the same domain and IP appear more than once. This
+// is harmless and will be cleaned up the next time the hosts file is saved.
+type DuplicateError struct {
+	Path string
+	Line int
+	// Text is the offending line in the hosts file
+	Text     string
+	Hostname *Hostname
+	// FirstLine is where Hostname was first seen in the hosts file
+	FirstLine int
+}
+
+func (e *DuplicateError) Error() string {
+	message := fmt.Sprintf("%sduplicate hostname entry for %s -> %s",
+		position(e.Path, e.Line), e.Hostname.Domain, e.Hostname.FormatIP())
+	if e.FirstLine > 0 {
+		message += fmt.Sprintf(" (first seen at line %d)", e.FirstLine)
+	}
+	return message
+}
+
+// ConflictError indicates the same domain appears more than once with
+// different IPs of the same version. The last one wins, but since we can't
+// tell which one the user actually wanted this is a real problem.
+type ConflictError struct {
+	Path string
+	Line int
+	// Text is the offending line in the hosts file
+	Text string
+	// Previous is the Hostname that was replaced by Hostname
+	Previous *Hostname
+	Hostname *Hostname
+	// FirstLine is where Previous was seen in the hosts file
+	FirstLine int
+}
+
+func (e *ConflictError) Error() string {
+	details := fmt.Sprintf("%s vs %s", e.Previous.FormatIP(), e.Hostname.FormatIP())
+	if e.FirstLine > 0 {
+		details += fmt.Sprintf(", first seen at line %d", e.FirstLine)
+	}
+	return fmt.Sprintf("%sconflicting entries for %s (%s)",
+		position(e.Path, e.Line), e.Hostname.Domain, details)
+}
+
+// InvalidIPError indicates an IP address could not be parsed.
+type InvalidIPError struct {
+	Path string
+	Line int
+	// Text is the offending line in the hosts file
+	Text string
+	IP   string
+}
+
+func (e *InvalidIPError) Error() string {
+	return fmt.Sprintf("%sunable to parse IP address %q", position(e.Path, e.Line), e.IP)
+}
+
+// MalformedLineError indicates a line in the hosts file is not a comment and
+// is not a valid hosts entry.
+type MalformedLineError struct {
+	Path string
+	Line int
+	// Text is the offending line in the hosts file
+	Text   string
+	Reason string
+}
+
+func (e *MalformedLineError) Error() string {
+	return fmt.Sprintf("%s%s: %q", position(e.Path, e.Line), e.Reason, e.Text)
+}
+
+// InvalidDomainError indicates a hostname is not valid. See ValidateDomain.
+type InvalidDomainError struct {
+	Path string
+	Line int
+	// Text is the offending line in the hosts file
+	Text   string
+	Domain string
+	Reason string
+}
+
+func (e *InvalidDomainError) Error() string {
+	return fmt.Sprintf("%sinvalid hostname %q: %s", position(e.Path, e.Line), e.Domain, e.Reason)
+}
@@ -0,0 +1,82 @@
+package hostess
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const EnvHostessExpire = `HOSTESS_EXPIRE`
+
+// expiresKey is the annotation key for Hostname.Expires
+const expiresKey = "expires"
+
+// untilLayouts are the formats ParseUntil accepts, most specific first
+var untilLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseUntil parses an expiry time like 2026-11-01T00:00Z. Times without a
+// timezone are in local time.
+func ParseUntil(until string) (time.Time, error) {
+	for _, layout := range untilLayouts {
+		if t, err := time.ParseInLocation(layout, until, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %q; expected a time like 2026-11-01T00:00Z", until)
+}
+
+// ShouldDisableExpired returns true if env HOSTESS_EXPIRE is set to
+// "disable", in which case expired entries are disabled instead of removed.
+func ShouldDisableExpired() bool {
+	return os.Getenv(EnvHostessExpire) == "disable"
+}
+
+// IsExpired returns true if the Hostname has an expiry time at or before now.
+func (h *Hostname) IsExpired(now time.Time) bool {
+	return !h.Expires.IsZero() && !h.Expires.After(now)
+}
+
+// Expired returns the Hostnames in the list that have expired as of now.
+func (h *Hostlist) Expired(now time.Time) Hostlist {
+	hostnames := Hostlist{}
+	for _, hostname := range *h {
+		if hostname.IsExpired(now) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
+
+// RemoveExpired removes Hostnames that have expired as of now, and returns
+// the ones it removed.
+func (h *Hostlist) RemoveExpired(now time.Time) Hostlist {
+	expired := h.Expired(now)
+	kept := Hostlist{}
+	for _, hostname := range *h {
+		if !hostname.IsExpired(now) {
+			kept = append(kept, hostname)
+		}
+	}
+	*h = kept
+	return expired
+}
+
+// DisableExpired disables enabled Hostnames that have expired as of now, and
+// returns the ones it disabled. The expiry time is kept so you can see why
+// they were disabled.
+func (h *Hostlist) DisableExpired(now time.Time) Hostlist {
+	disabled := Hostlist{}
+	for _, hostname := range h.Expired(now) {
+		if hostname.Enabled {
+			hostname.Enabled = false
+			disabled = append(disabled, hostname)
+		}
+	}
+	return disabled
+}
@@ -0,0 +1,127 @@
+package hostess_test
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+const expireHostfile = `127.0.0.1 localhost
+10.0.0.5 devbox # dev box [expires=2026-11-01T00:00:00Z]
+10.0.0.6 incident.local old.local # [expires=2020-01-01T00:00:00Z]
+`
+
+func TestParseExpires(t *testing.T) {
+	hostfile := LoadTempHostfile(t, expireHostfile)
+	defer os.Remove(hostfile.Path)
+
+	index := hostfile.Hosts.IndexOfDomainV("devbox", 4)
+	expected := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	if index == -1 || !hostfile.Hosts[index].Expires.Equal(expected) {
+		t.Fatalf("Expected devbox to expire at %s, found %v", expected, hostfile.Hosts)
+	}
+	if hostfile.Lines[1].Comment != "dev box" {
+		t.Errorf("Expected the comment without the annotation, found %q", hostfile.Lines[1].Comment)
+	}
+
+	// Nothing changed so we should get back exactly what we read
+	if output := string(hostfile.Format()); output != expireHostfile {
+		t.Error(Diff(expireHostfile, output))
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	removed := hostfile.Hosts.RemoveExpired(now)
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 expired hostnames, found %v", removed)
+	}
+
+	// Changing the expiry time regenerates the line and keeps the comment
+	hostfile.Hosts[hostfile.Hosts.IndexOfDomainV("devbox", 4)].Expires = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected2 := `127.0.0.1 localhost
+10.0.0.5 devbox # dev box [expires=2027-01-01T00:00:00Z]
+`
+	if output := string(hostfile.Format()); output != expected2 {
+		t.Error(Diff(expected2, output))
+	}
+}
+
+func TestDisableExpired(t *testing.T) {
+	hostfile := LoadTempHostfile(t, expireHostfile)
+	defer os.Remove(hostfile.Path)
+
+	now := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	disabled := hostfile.Hosts.DisableExpired(now)
+	if len(disabled) != 3 {
+		t.Errorf("Expected 3 disabled hostnames, found %v", disabled)
+	}
+	// They're already disabled so there's nothing left to do
+	if disabled := hostfile.Hosts.DisableExpired(now); len(disabled) != 0 {
+		t.Errorf("Expected nothing to disable, found %v", disabled)
+	}
+
+	expected := `127.0.0.1 localhost
+# 10.0.0.5 devbox # dev box [expires=2026-11-01T00:00:00Z]
+# 10.0.0.6 incident.local old.local # [expires=2020-01-01T00:00:00Z]
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestFormatExpires(t *testing.T) {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("a.local", "10.0.0.1", true))
+	temporary := hostess.MustHostname("b.local", "10.0.0.1", true)
+	temporary.Expires = time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	hosts.Add(temporary)
+
+	// Hostnames that expire get their own line
+	expected := `10.0.0.1 a.local
+10.0.0.1 b.local # [expires=2026-11-01T12:00:00Z]
+`
+	if output := string(hosts.FormatLinux()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	data, err := json.Marshal(temporary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"expires":"2026-11-01T12:00:00Z"`) {
+		t.Errorf("Expected expires in JSON, found %s", data)
+	}
+	decoded := &hostess.Hostname{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Expires.Equal(temporary.Expires) {
+		t.Errorf("Expected %s, found %s", temporary.Expires, decoded.Expires)
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	cases := map[string]time.Time{
+		"2026-11-01T00:00Z":         time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		"2026-11-01T00:00:00Z":      time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		"2026-11-01T02:00:00+02:00": time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		"2026-11-01":                time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local),
+	}
+	for input, expected := range cases {
+		until, err := hostess.ParseUntil(input)
+		if err != nil {
+			t.Errorf("ParseUntil(%q): %s", input, err)
+			continue
+		}
+		if !until.Equal(expected) {
+			t.Errorf("ParseUntil(%q): expected %s, found %s", input, expected, until)
+		}
+	}
+
+	if _, err := hostess.ParseUntil("tomorrow"); err == nil {
+		t.Error("Expected an error parsing tomorrow")
+	}
+}
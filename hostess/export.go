@@ -0,0 +1,306 @@
+package hostess
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The Format* functions in this file export the Hostlist as configuration for
+// other programs, like DNS servers. Only enabled Hostnames are exported, and
+// IPv6 addresses with a zone are left out since they only mean something on
+// this machine.
+//
+// The DNS server and container formats also leave out loopback, link-local,
+// and multicast entries like localhost and ip6-allnodes. Every machine and
+// container has its own, so exporting the ones from this hosts file would
+// only point clients back at themselves, or at this machine's hostname on
+// 127.0.1.1.
+
+// exported returns a sorted copy of the Hostnames in h that should be exported
+func (h *Hostlist) exported() Hostlist {
+	hostnames := Hostlist{}
+	for _, hostname := range *h {
+		if hostname.Enabled && hostname.Zone == "" {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	hostnames.Sort()
+	return hostnames
+}
+
+// ip6Localnet is the fe00::0 address Debian and Ubuntu give to ip6-localnet
+var ip6Localnet = net.ParseIP("fe00::")
+
+// machineLocal returns true if hostname only means something on the machine
+// the hosts file is on
+func machineLocal(hostname *Hostname) bool {
+	ip := hostname.IP
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || ip.Equal(net.IPv4bcast) || ip.Equal(ip6Localnet) ||
+		loopbackDomains[DomainKey(hostname.Domain)]
+}
+
+// served returns the Hostnames in h that other machines can use, leaving out
+// the machineLocal ones
+func (h *Hostlist) served() *Hostlist {
+	hostnames := Hostlist{}
+	for _, hostname := range *h {
+		if !machineLocal(hostname) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return &hostnames
+}
+
+// domainAddresses is a domain and the IPs it resolves to
+type domainAddresses struct {
+	Domain string
+	IPv4   []net.IP
+	IPv6   []net.IP
+}
+
+// IPs returns the IPv4 addresses followed by the IPv6 addresses
+func (d *domainAddresses) IPs() []net.IP {
+	return append(append([]net.IP{}, d.IPv4...), d.IPv6...)
+}
+
+// byDomain groups the exported Hostnames by domain, so IPv4 and IPv6
+// addresses for the same domain are together. Domains are in the order they
+// sort in the Hostlist.
+func (h *Hostlist) byDomain() []*domainAddresses {
+	domains := []*domainAddresses{}
+	index := map[string]*domainAddresses{}
+	for _, hostname := range h.exported() {
+		key := DomainKey(hostname.Domain)
+		addresses, ok := index[key]
+		if !ok {
+			addresses = &domainAddresses{Domain: hostname.Domain}
+			index[key] = addresses
+			domains = append(domains, addresses)
+		}
+		if hostname.IPv6 {
+			addresses.IPv6 = appendIP(addresses.IPv6, hostname.IP)
+		} else {
+			addresses.IPv4 = appendIP(addresses.IPv4, hostname.IP)
+		}
+	}
+	return domains
+}
+
+// ipDomains is an IP and the domains that resolve to it
+type ipDomains struct {
+	IP      net.IP
+	Domains []string
+}
+
+// byIP groups the exported Hostnames by IP, the same way FormatLinux does.
+// The first domain for each IP is the one reverse lookups should return.
+func (h *Hostlist) byIP() []*ipDomains {
+	ips := []*ipDomains{}
+	index := map[string]*ipDomains{}
+	seen := map[string]bool{}
+	for _, hostname := range h.exported() {
+		key := hostname.IP.String()
+		domains, ok := index[key]
+		if !ok {
+			domains = &ipDomains{IP: hostname.IP}
+			index[key] = domains
+			ips = append(ips, domains)
+		}
+		if !seen[key+" "+DomainKey(hostname.Domain)] {
+			seen[key+" "+DomainKey(hostname.Domain)] = true
+			domains.Domains = append(domains.Domains, hostname.Domain)
+		}
+	}
+	return ips
+}
+
+// appendIP appends ip to ips unless it's already there
+func appendIP(ips []net.IP, ip net.IP) []net.IP {
+	for _, existing := range ips {
+		if existing.Equal(ip) {
+			return ips
+		}
+	}
+	return append(ips, ip)
+}
+
+// fqdn adds a trailing dot to domain, if it doesn't have one
+func fqdn(domain string) string {
+	return strings.TrimSuffix(domain, ".") + "."
+}
+
+// FormatDnsmasq exports the Hostlist as dnsmasq host-record lines, which
+// answer forward and reverse lookups like the hosts file does. A domain's IPv4
+// and IPv6 addresses share a line:
+//
+//	host-record=api.local,10.0.0.1,fd00::1
+func (h *Hostlist) FormatDnsmasq() []byte {
+	out := bytes.Buffer{}
+	for _, domain := range h.served().byDomain() {
+		// host-record takes at most one address of each version
+		fields := []string{domain.Domain}
+		if len(domain.IPv4) > 0 {
+			fields = append(fields, domain.IPv4[0].String())
+		}
+		if len(domain.IPv6) > 0 {
+			fields = append(fields, domain.IPv6[0].String())
+		}
+		out.WriteString("host-record=" + strings.Join(fields, ",") + "\n")
+	}
+	return out.Bytes()
+}
+
+// FormatDnsmasqAddress exports the Hostlist as dnsmasq address lines. Unlike
+// host-record, these also match subdomains, so api.local also answers for
+// www.api.local:
+//
+//	address=/api.local/10.0.0.1
+//	address=/api.local/fd00::1
+func (h *Hostlist) FormatDnsmasqAddress() []byte {
+	out := bytes.Buffer{}
+	for _, domain := range h.served().byDomain() {
+		for _, ip := range domain.IPs() {
+			out.WriteString(fmt.Sprintf("address=/%s/%s\n", domain.Domain, ip))
+		}
+	}
+	return out.Bytes()
+}
+
+// FormatUnbound exports the Hostlist as unbound local-data lines for forward
+// lookups, followed by local-data-ptr lines for reverse lookups:
+//
+//	local-data: "api.local. IN A 10.0.0.1"
+//	local-data: "api.local. IN AAAA fd00::1"
+//	local-data-ptr: "10.0.0.1 api.local."
+func (h *Hostlist) FormatUnbound() []byte {
+	out := bytes.Buffer{}
+	for _, domain := range h.served().byDomain() {
+		for _, ip := range domain.IPv4 {
+			out.WriteString(fmt.Sprintf("local-data: \"%s IN A %s\"\n", fqdn(domain.Domain), ip))
+		}
+		for _, ip := range domain.IPv6 {
+			out.WriteString(fmt.Sprintf("local-data: \"%s IN AAAA %s\"\n", fqdn(domain.Domain), ip))
+		}
+	}
+	for _, ip := range h.served().byIP() {
+		out.WriteString(fmt.Sprintf("local-data-ptr: \"%s %s\"\n", ip.IP, fqdn(ip.Domains[0])))
+	}
+	return out.Bytes()
+}
+
+// FormatCoreDNS exports the Hostlist as a CoreDNS hosts plugin block, to go in
+// a server block in your Corefile. Queries for other names fall through to the
+// next plugin.
+//
+//	hosts {
+//	    10.0.0.1 api.local
+//	    fallthrough
+//	}
+func (h *Hostlist) FormatCoreDNS() []byte {
+	out := bytes.Buffer{}
+	out.WriteString("hosts {\n")
+	for _, ip := range h.served().byIP() {
+		out.WriteString(fmt.Sprintf("    %s %s\n", ip.IP, strings.Join(ip.Domains, " ")))
+	}
+	out.WriteString("    fallthrough\n")
+	out.WriteString("}\n")
+	return out.Bytes()
+}
+
+// FormatHostAliases exports the Hostlist as the hostAliases field of a
+// Kubernetes Pod spec, with the hostnames for each IP grouped together like
+// FormatLinux does:
+//
+//	hostAliases:
+//	- ip: "10.0.0.1"
+//	  hostnames:
+//	  - "api.local"
+func (h *Hostlist) FormatHostAliases() []byte {
+	out := bytes.Buffer{}
+	out.WriteString("hostAliases:\n")
+	for _, ip := range h.served().byIP() {
+		out.WriteString(fmt.Sprintf("- ip: %q\n", ip.IP.String()))
+		out.WriteString("  hostnames:\n")
+		for _, domain := range ip.Domains {
+			out.WriteString(fmt.Sprintf("  - %q\n", domain))
+		}
+	}
+	return out.Bytes()
+}
+
+// FormatDockerAddHost exports the Hostlist as docker run flags, one per line:
+//
+//	--add-host api.local:10.0.0.1
+func (h *Hostlist) FormatDockerAddHost() []byte {
+	out := bytes.Buffer{}
+	for _, ip := range h.served().byIP() {
+		for _, domain := range ip.Domains {
+			out.WriteString(fmt.Sprintf("--add-host %s:%s\n", domain, ip.IP))
+		}
+	}
+	return out.Bytes()
+}
+
+// FormatComposeExtraHosts exports the Hostlist as the extra_hosts field of a
+// Docker Compose service:
+//
+//	extra_hosts:
+//	  - "api.local:10.0.0.1"
+func (h *Hostlist) FormatComposeExtraHosts() []byte {
+	out := bytes.Buffer{}
+	out.WriteString("extra_hosts:\n")
+	for _, ip := range h.served().byIP() {
+		for _, domain := range ip.Domains {
+			out.WriteString(fmt.Sprintf("  - \"%s:%s\"\n", domain, ip.IP))
+		}
+	}
+	return out.Bytes()
+}
+
+// resolveAddress formats ip for curl and Chrome, which need brackets around
+// IPv6 addresses
+func resolveAddress(ip net.IP) string {
+	if ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+	return ip.String()
+}
+
+// FormatCurlResolve exports the Hostlist as curl --resolve flags for each
+// port, one per line, so you can use the hosts entries without changing the
+// hosts file. curl only keeps the last --resolve for a host and port, so a
+// domain's addresses share one flag (this needs curl 7.59 or newer):
+//
+//	--resolve api.local:443:10.0.0.1,[fd00::1]
+func (h *Hostlist) FormatCurlResolve(ports []int) []byte {
+	out := bytes.Buffer{}
+	for _, domain := range h.byDomain() {
+		addresses := []string{}
+		for _, ip := range domain.IPs() {
+			addresses = append(addresses, resolveAddress(ip))
+		}
+		for _, port := range ports {
+			out.WriteString(fmt.Sprintf("--resolve %s:%d:%s\n", domain.Domain, port, strings.Join(addresses, ",")))
+		}
+	}
+	return out.Bytes()
+}
+
+// FormatChromeRules exports the Hostlist as a Chrome (or Chromium)
+// --host-resolver-rules flag. Chrome maps each domain to a single IP, so
+// domains with IPv4 and IPv6 addresses use the IPv4 address.
+//
+//	--host-resolver-rules="MAP api.local 10.0.0.1, MAP www.local 10.0.0.1"
+func (h *Hostlist) FormatChromeRules() []byte {
+	rules := []string{}
+	for _, domain := range h.byDomain() {
+		rules = append(rules, fmt.Sprintf("MAP %s %s", domain.Domain, resolveAddress(domain.IPs()[0])))
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return []byte(fmt.Sprintf("--host-resolver-rules=\"%s\"\n", strings.Join(rules, ", ")))
+}
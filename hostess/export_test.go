@@ -0,0 +1,148 @@
+package hostess_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func exportHostlist() *hostess.Hostlist {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("localhost", "127.0.0.1", true))
+	hosts.Add(hostess.MustHostname("localhost", "::1", true))
+	hosts.Add(hostess.MustHostname("localhost", "fe80::1%lo0", true))
+	hosts.Add(hostess.MustHostname("api.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("www.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("api.local", "fd00::1", true))
+	hosts.Add(hostess.MustHostname("old.local", "10.0.0.2", false))
+	return hosts
+}
+
+func TestFormatDnsmasq(t *testing.T) {
+	hosts := exportHostlist()
+
+	expected := `host-record=api.local,10.0.0.1,fd00::1
+host-record=www.local,10.0.0.1
+`
+	if output := string(hosts.FormatDnsmasq()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	expected = `address=/api.local/10.0.0.1
+address=/api.local/fd00::1
+address=/www.local/10.0.0.1
+`
+	if output := string(hosts.FormatDnsmasqAddress()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestFormatUnbound(t *testing.T) {
+	hosts := exportHostlist()
+
+	expected := `local-data: "api.local. IN A 10.0.0.1"
+local-data: "api.local. IN AAAA fd00::1"
+local-data: "www.local. IN A 10.0.0.1"
+local-data-ptr: "10.0.0.1 api.local."
+local-data-ptr: "fd00::1 api.local."
+`
+	if output := string(hosts.FormatUnbound()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestFormatCoreDNS(t *testing.T) {
+	hosts := exportHostlist()
+
+	expected := `hosts {
+    10.0.0.1 api.local www.local
+    fd00::1 api.local
+    fallthrough
+}
+`
+	if output := string(hosts.FormatCoreDNS()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestFormatContainers(t *testing.T) {
+	hosts := exportHostlist()
+
+	expected := `hostAliases:
+- ip: "10.0.0.1"
+  hostnames:
+  - "api.local"
+  - "www.local"
+- ip: "fd00::1"
+  hostnames:
+  - "api.local"
+`
+	if output := string(hosts.FormatHostAliases()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	expected = `--add-host api.local:10.0.0.1
+--add-host www.local:10.0.0.1
+--add-host api.local:fd00::1
+`
+	if output := string(hosts.FormatDockerAddHost()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	expected = `extra_hosts:
+  - "api.local:10.0.0.1"
+  - "www.local:10.0.0.1"
+  - "api.local:fd00::1"
+`
+	if output := string(hosts.FormatComposeExtraHosts()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestFormatCurlAndChrome(t *testing.T) {
+	hosts := exportHostlist()
+
+	// Dual-stack domains get one flag per port with both addresses, since curl
+	// only uses the last --resolve for a host and port
+	expected := `--resolve localhost:80:127.0.0.1,[::1]
+--resolve localhost:8443:127.0.0.1,[::1]
+--resolve api.local:80:10.0.0.1,[fd00::1]
+--resolve api.local:8443:10.0.0.1,[fd00::1]
+--resolve www.local:80:10.0.0.1
+--resolve www.local:8443:10.0.0.1
+`
+	if output := string(hosts.FormatCurlResolve([]int{80, 8443})); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	expected = `--host-resolver-rules="MAP localhost 127.0.0.1, MAP api.local 10.0.0.1, MAP www.local 10.0.0.1"` + "\n"
+	if output := string(hosts.FormatChromeRules()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestFilterForExport(t *testing.T) {
+	hosts := exportHostlist()
+
+	matched, err := hosts.FilterByDomainPattern("*.LOCAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "MAP api.local 10.0.0.1, MAP www.local 10.0.0.1"
+	if output := string(matched.FormatChromeRules()); output != "--host-resolver-rules=\""+expected+"\"\n" {
+		t.Errorf("Expected %s, found %s", expected, output)
+	}
+	if _, err := hosts.FilterByDomainPattern("[a-"); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+
+	_, network, err := net.ParseCIDR("fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inNetwork := hosts.FilterByNetwork(network)
+	if len(inNetwork) != 1 || inNetwork[0].FormatIP() != "fd00::1" {
+		t.Errorf("Expected only fd00::1, found %v", inNetwork)
+	}
+}
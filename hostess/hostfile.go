@@ -40,16 +40,36 @@ ff02::3 ip6-allhosts
 `
 
 // Hostfile represents /etc/hosts (or a similar file, depending on OS), and
-// includes a list of Hostnames. Hostfile includes
+// includes a list of Hostnames. Hostfile also keeps the Lines it parsed so
+// comments, blank lines, and untouched entries survive a Save.
+//
+// If Block is set, hostess only manages the part of the file between the
+// # BEGIN <Block> and # END <Block> comments. Hosts and Lines only contain
+// what is inside the block, and everything outside it is written back exactly
+// as it was read.
+//
+// Profiles lists the profile sections found in the file or added with
+// CreateProfile. Use ListProfiles to also include profiles that only exist
+// because a Hostname in Hosts refers to them.
 type Hostfile struct {
-	Path  string
-	Hosts Hostlist
-	data  []byte
+	Path     string
+	Block    string
+	Hosts    Hostlist
+	Lines    []*Line
+	Profiles []string
+	data     []byte
+	read     bool
+	block    *managedBlock
+	// canonical is set by Canonicalize so Rebase keeps the file canonical.
+	canonical bool
+	// blockErr is set if Parse could not find the managed block, in which
+	// case Save refuses to write.
+	blockErr error
 }
 
 // NewHostfile creates a new Hostfile object from the specified file.
 func NewHostfile() *Hostfile {
-	return &Hostfile{GetHostsPath(), Hostlist{}, []byte{}}
+	return &Hostfile{Path: GetHostsPath(), Block: GetBlockName(), Hosts: Hostlist{}, data: []byte{}}
 }
 
 // GetHostsPath returns the location of the hostfile; either env HOSTESS_PATH
@@ -90,8 +110,13 @@ func ParseLine(line string) (Hostlist, error) {
 		line = TrimWS(line[1:])
 	}
 
-	// Parse other #s for actual comments
-	line = strings.Split(line, "#")[0]
+	// Parse other #s for actual comments, which may have an annotation
+	var comment string
+	var annotation map[string]string
+	if index := strings.Index(line, "#"); index > -1 {
+		comment, annotation = splitAnnotation(line[index+1:])
+		line = line[:index]
+	}
 
 	// Replace tabs and multispaces with single spaces throughout
 	line = strings.Replace(line, "\t", " ", -1)
@@ -112,10 +137,13 @@ func ParseLine(line string) (Hostlist, error) {
 
 	// if LooksLikeIPv4(ip) || LooksLikeIPv6(ip) {
 	for _, v := range domains {
-		hostname, err := NewHostname(v, ip, enabled)
+		hostname, err := newHostname(v, ip, enabled, !IsStrict())
 		if err != nil {
 			return nil, err
 		}
+		if err := hostname.applyComment(comment, annotation); err != nil {
+			return nil, err
+		}
 		hostnames = append(hostnames, hostname)
 	}
 	// }
@@ -132,24 +160,117 @@ func MustParseLine(line string) Hostlist {
 	return hostlist
 }
 
-// Parse reads
+// Parse reads the hostfile data into Lines and adds each hosts entry it finds
+// to Hosts. Parse returns all of the problems it finds, with the line where it
+// found them: *DuplicateError, *ConflictError, *InvalidIPError,
+// *InvalidDomainError, and *MalformedLineError.
 func (h *Hostfile) Parse() []error {
 	var errs []error
-	var line = 1
-	for _, v := range strings.Split(string(h.data), "\n") {
-		hostnames, _ := ParseLine(v)
-		// if err != nil {
-		// 	log.Printf("Error parsing line %d: %s\n", line, err)
-		// }
-		for _, hostname := range hostnames {
-			err := h.Hosts.Add(hostname)
+	h.Lines, h.block, errs = h.parse(h.data, &h.Hosts)
+	h.Profiles = declaredProfiles(h.Lines)
+	h.blockErr = nil
+	if h.block == nil {
+		h.blockErr = errs[0]
+	}
+	return errs
+}
+
+// parse finds the managed block in data (or uses all of data if Block is not
+// set) and parses it. If the managed block can't be found, parse returns a nil
+// *managedBlock and the reason.
+func (h *Hostfile) parse(data []byte, hosts *Hostlist) ([]*Line, *managedBlock, []error) {
+	block, err := findBlock(h.Path, data, h.Block)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+	lines, errs := parseData(h.Path, block.body, block.offset, hosts)
+	return lines, block, errs
+}
+
+// parseData splits data into Lines and adds each hosts entry it finds to
+// hosts. path and offset (the number of lines in the file before data) are
+// only used for error messages.
+func parseData(path string, data []byte, offset int, hosts *Hostlist) ([]*Line, []error) {
+	var lines []*Line
+	var errs []error
+	var line = offset + 1
+	if len(data) == 0 {
+		return lines, errs
+	}
+
+	// firstSeen tracks where we found each hostname for error messages, and
+	// firstEnabled where we found the first enabled entry for each domain in
+	// any profile, for conflicts between profiles
+	firstSeen := map[string]int{}
+	firstEnabled := map[string]int{}
+	// profile is the profile section we're in, and profileLine is where it
+	// started
+	profile, profileLine := "", 0
+	malformed := func(line int, text, reason string) error {
+		return &MalformedLineError{Path: path, Line: line, Text: TrimWS(text), Reason: reason}
+	}
+
+	for _, v := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		parsed, err := NewLine(v)
+		lines = append(lines, parsed)
+		if err != nil {
+			errs = append(errs, locateError(err, path, line, v, 0))
+		}
+		switch parsed.Kind {
+		case ProfileBeginLine:
+			if profile != "" {
+				errs = append(errs, malformed(line, v, fmt.Sprintf("profile %s starts inside profile %s", parsed.Profile, profile)))
+			}
+			profile, profileLine = parsed.Profile, line
+		case ProfileEndLine:
+			if parsed.Profile != profile {
+				errs = append(errs, malformed(line, v, fmt.Sprintf("found the end of profile %s without the beginning", parsed.Profile)))
+			}
+			profile = ""
+		}
+		for _, hostname := range parsed.Hostnames {
+			hostname.Profile = profile
+			key, enabledKey := hostnameKey(hostname), versionKey(hostname)
+			err := hosts.Add(hostname)
 			if err != nil {
-				errs = append(errs, err)
+				firstLine := firstSeen[key]
+				if conflict, ok := err.(*ConflictError); ok && conflict.Previous.Profile != profile {
+					firstLine = firstEnabled[enabledKey]
+				}
+				errs = append(errs, locateError(err, path, line, v, firstLine))
+			}
+			if _, ok := firstSeen[key]; !ok {
+				firstSeen[key] = line
+			}
+			if _, ok := firstEnabled[enabledKey]; !ok && hostname.Enabled {
+				firstEnabled[enabledKey] = line
 			}
 		}
 		line++
 	}
-	return errs
+	if profile != "" {
+		errs = append(errs, malformed(profileLine, ProfileBegin(profile), fmt.Sprintf("found the beginning of profile %s without the end", profile)))
+	}
+	return lines, errs
+}
+
+// locateError fills in the location details on errors from NewLine and
+// Hostlist.Add.
+func locateError(err error, path string, line int, text string, firstLine int) error {
+	text = TrimWS(text)
+	switch e := err.(type) {
+	case *DuplicateError:
+		e.Path, e.Line, e.Text, e.FirstLine = path, line, text, firstLine
+	case *ConflictError:
+		e.Path, e.Line, e.Text, e.FirstLine = path, line, text, firstLine
+	case *InvalidIPError:
+		e.Path, e.Line, e.Text = path, line, text
+	case *MalformedLineError:
+		e.Path, e.Line, e.Text = path, line, text
+	case *InvalidDomainError:
+		e.Path, e.Line, e.Text = path, line, text
+	}
+	return err
 }
 
 // Read the contents of the hostfile from disk
@@ -157,6 +278,7 @@ func (h *Hostfile) Read() error {
 	data, err := ioutil.ReadFile(h.Path)
 	if err == nil {
 		h.data = data
+		h.read = true
 	}
 	return err
 }
@@ -184,44 +306,72 @@ func (h *Hostfile) GetData() []byte {
 
 // Format takes the current list of Hostnames in this Hostfile and turns it
 // into a string suitable for use as an /etc/hosts file.
-// Sorting uses the following logic:
-// 1. List is sorted by IP address
-// 2. Commented items are left in place
-// 3. 127.* appears at the top of the list (so boot resolvers don't break)
-// 4. When present, localhost will always appear first in the domain list
+//
+// If the Hostfile was parsed from disk, the original layout is preserved:
+// comments, blank lines, and unchanged entries are written back verbatim,
+// lines with changed entries are regenerated in place, and new entries are
+// appended at the end. Otherwise (or after DiscardLayout) the whole file is
+// formatted by Hostlist.Format.
+//
+// Hostnames in a profile are kept in that profile's section. Without a
+// layout, Hostnames that are not in a profile come first, followed by a
+// section for each profile.
+//
+// If Block is set, only the managed block is formatted this way. If the file
+// does not have a managed block yet, one is added at the end.
 func (h *Hostfile) Format() []byte {
-	return h.Hosts.Format()
+	var body []byte
+	if len(h.Lines) == 0 {
+		body = formatHosts(h.Hosts, h.ListProfiles())
+	} else {
+		body = formatLines(h.Lines, h.Hosts, h.ListProfiles())
+	}
+
+	block := h.block
+	if block == nil {
+		block = &managedBlock{before: h.data}
+	}
+	return block.wrap(h.Block, body)
+}
+
+// DiscardLayout forgets the comments, blank lines, and line order that were
+// read from disk, so the next Format or Save rewrites the entire file (or the
+// entire managed block) in the canonical format.
+func (h *Hostfile) DiscardLayout() {
+	h.Lines = nil
+}
+
+// Canonicalize rewrites the entries in the canonical format, like
+// DiscardLayout, but keeps comments and blank lines where they were. Each run
+// of consecutive entry lines is sorted and formatted by Hostlist.Format, and
+// duplicates are removed. Hostnames that were not read from disk are added at
+// the end of the file (or their profile's section) as usual.
+func (h *Hostfile) Canonicalize() {
+	h.canonical = true
+	if h.Lines != nil {
+		h.Lines = canonicalLines(h.Lines, h.Hosts)
+	}
+}
+
+// BlockError returns the reason Parse could not find the managed block, or nil
+// if it was found (or Block is not set).
+func (h *Hostfile) BlockError() error {
+	return h.blockErr
 }
 
 // Save writes the Hostfile to disk to /etc/hosts or to the location specified
-// by the HOSTESS_PATH environment variable (if set).
+// by the HOSTESS_PATH environment variable (if set). See writeHostsFile for
+// the platform-specific details of how the file is replaced.
+//
+// If the file was changed by someone else since we read it, Save calls Rebase
+// to keep their changes, and returns an *ExternalChangeError without writing
+// anything if their changes conflict with ours.
 func (h *Hostfile) Save() error {
-	var file *os.File
-	var err error
-
-	// TODO break platform-specific code into separate functions
-	// Windows wants the file to be truncated before it's opened. Then we re-
-	// write the entire file contents. Truncating up front is risky but I don't
-	// know of a better way to do it.
-	if runtime.GOOS == "windows" {
-		if err := os.Truncate(h.Path, 0); err != nil {
-			return err
-		}
-
-		file, err = os.OpenFile(h.Path, os.O_RDWR, 0644)
-	} else {
-		// TODO use atomic write-and-rename on Unix
-		// I think an earlier version of the program did this but it did not
-		// work on Windows so it was rolled back. We can probably get that code
-		// from history.
-		file, err = os.OpenFile(h.Path, os.O_RDWR|os.O_APPEND|os.O_TRUNC, 0644)
+	if h.blockErr != nil {
+		return h.blockErr
 	}
-	if err != nil {
+	if err := h.Rebase(); err != nil {
 		return err
 	}
-
-	defer file.Close()
-	_, err = file.Write(h.Format())
-
-	return err
+	return writeHostsFile(h.Path, h.Format())
 }
@@ -18,18 +18,34 @@ const ipv4Pass = `
 127.0.1.1
 10.200.30.50
 99.99.99.99
-999.999.999.999
 0.1.1.0
 `
 
 const ipv4Fail = `
 1234.1.1.1
+999.999.999.999
 123.5.6
 12.12
 76.76.67.67.45
+::1
+`
+
+const ipv6Pass = `
+::1
+fe00::0
+ff02::1
+fe:23b3:890e:342e::ef
+fe80::1%lo0
+::ffff:10.0.0.1
 `
 
-const ipv6 = ``
+const ipv6Fail = `
+dead:beef:
+(((:
+1:2:3:4:5:6:7:8:9
+fe80::1%
+127.0.0.1
+`
 const domain = "localhost"
 const ip = "127.0.0.1"
 const enabled = true
@@ -241,3 +257,260 @@ func TestSaveHostfile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+const commentedHostfile = `##
+# Host Database
+##
+
+127.0.0.1	localhost
+10.0.0.5    devbox   # dev box, ask ops before changing
+# 10.0.0.6 oldbox
+
+# The following lines are desirable for IPv6 capable hosts
+::1     localhost ip6-localhost
+`
+
+// ParseHostfile loads data as a Hostfile without leaving anything on disk.
+func ParseHostfile(t *testing.T, data string) *hostess.Hostfile {
+	t.Helper()
+	hostfile := LoadTempHostfile(t, data)
+	os.Remove(hostfile.Path)
+	return hostfile
+}
+
+func TestParseLines(t *testing.T) {
+	hostfile := ParseHostfile(t, commentedHostfile)
+
+	kinds := []hostess.LineKind{
+		hostess.CommentLine,
+		hostess.CommentLine,
+		hostess.CommentLine,
+		hostess.BlankLine,
+		hostess.EntryLine,
+		hostess.EntryLine,
+		hostess.EntryLine,
+		hostess.BlankLine,
+		hostess.CommentLine,
+		hostess.EntryLine,
+	}
+	if len(hostfile.Lines) != len(kinds) {
+		t.Fatalf("Expected %d lines, found %d", len(kinds), len(hostfile.Lines))
+	}
+	for index, kind := range kinds {
+		if hostfile.Lines[index].Kind != kind {
+			t.Errorf("Expected line %d (%q) to be kind %d, found %d",
+				index+1, hostfile.Lines[index].Raw, kind, hostfile.Lines[index].Kind)
+		}
+	}
+
+	const comment = "dev box, ask ops before changing"
+	if hostfile.Lines[5].Comment != comment {
+		t.Errorf("Expected comment %q, found %q", comment, hostfile.Lines[5].Comment)
+	}
+}
+
+func TestFormatPreservesLayout(t *testing.T) {
+	hostfile := ParseHostfile(t, commentedHostfile)
+
+	output := string(hostfile.Format())
+	if output != commentedHostfile {
+		t.Errorf("Expected unchanged hostfile to round-trip: %s", Diff(commentedHostfile, output))
+	}
+}
+
+func TestFormatOnlyChangesTouchedLines(t *testing.T) {
+	hostfile := ParseHostfile(t, commentedHostfile)
+
+	hostfile.Hosts.Add(hostess.MustHostname("devbox", "10.0.0.7", true))
+	hostfile.Hosts.Enable("oldbox")
+	hostfile.Hosts.RemoveDomainV("ip6-localhost", 6)
+	hostfile.Hosts.Add(hostess.MustHostname("newbox", "10.0.0.8", true))
+
+	expected := `##
+# Host Database
+##
+
+127.0.0.1	localhost
+10.0.0.7 devbox # dev box, ask ops before changing
+10.0.0.6 oldbox
+
+# The following lines are desirable for IPv6 capable hosts
+::1 localhost
+10.0.0.8 newbox
+`
+
+	output := string(hostfile.Format())
+	if output != expected {
+		t.Errorf("Unexpected output: %s", Diff(expected, output))
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	const data = `10.0.0.9 cdn.example.com # INC-1234 bypass CDN [owner=alice team="web ops"]
+# 10.0.0.5 devbox # dev box
+`
+	hostfile := ParseHostfile(t, data)
+
+	cdn := hostfile.Hosts[0]
+	if cdn.Comment != "INC-1234 bypass CDN" || cdn.Metadata["owner"] != "alice" || cdn.Metadata["team"] != "web ops" {
+		t.Errorf("Expected comment and metadata, found %+v", cdn)
+	}
+	if devbox := hostfile.Hosts[1]; devbox.Comment != "dev box" || devbox.Metadata != nil {
+		t.Errorf("Expected comment on disabled entry, found %+v", devbox)
+	}
+
+	// Comments stay with their entries even when we rewrite the file
+	hostfile.DiscardLayout()
+	hostfile.Hosts.Add(hostess.MustHostname("cdn.example.com", "10.0.0.10", true))
+	expected := `# 10.0.0.5 devbox # dev box
+10.0.0.10 cdn.example.com # INC-1234 bypass CDN [owner=alice team="web ops"]
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestDiscardLayout(t *testing.T) {
+	hostfile := ParseHostfile(t, commentedHostfile)
+	hostfile.DiscardLayout()
+
+	expected := string(hostfile.Hosts.Format())
+	output := string(hostfile.Format())
+	if output != expected {
+		t.Errorf("Expected canonical output: %s", Diff(expected, output))
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	const data = `##
+# Host Database
+##
+
+10.0.0.5    devbox   # dev box, ask ops before changing
+# 10.0.0.6 oldbox
+127.0.0.1	localhost
+
+# The following lines are desirable for IPv6 capable hosts
+::1     localhost ip6-localhost
+`
+	hostfile := ParseHostfile(t, data)
+	hostfile.Canonicalize()
+
+	// Each group of entries is sorted, but comments and blank lines stay
+	// where they were
+	expected := `##
+# Host Database
+##
+
+127.0.0.1 localhost
+10.0.0.5 devbox # dev box, ask ops before changing
+# 10.0.0.6 oldbox
+
+# The following lines are desirable for IPv6 capable hosts
+::1 localhost ip6-localhost
+`
+	output := string(hostfile.Format())
+	if output != expected {
+		t.Errorf("Unexpected output: %s", Diff(expected, output))
+	}
+
+	// Formatting canonical output again doesn't change anything
+	hostfile = ParseHostfile(t, expected)
+	hostfile.Canonicalize()
+	if output := string(hostfile.Format()); output != expected {
+		t.Errorf("Expected canonical output to round-trip: %s", Diff(expected, output))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	const data = `127.0.0.1 localhost
+10.0.0.2 api.local
+# api.local is moving to 10.0.0.3
+999.0.0.1 broken.local
+10.0.0.4
+10.0.0.2 api.local
+10.0.0.3 api.local
+`
+	tempfile, err := ioutil.TempFile("", "hostess-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempfile.Name())
+	if _, err := tempfile.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	tempfile.Close()
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = tempfile.Name()
+	if err := hostfile.Read(); err != nil {
+		t.Fatal(err)
+	}
+	errs := hostfile.Parse()
+	if len(errs) != 4 {
+		t.Fatalf("Expected 4 errors, found %d: %v", len(errs), errs)
+	}
+
+	if e, ok := errs[0].(*hostess.InvalidIPError); !ok || e.Line != 4 || e.IP != "999.0.0.1" {
+		t.Errorf("Expected invalid IP on line 4, found %#v", errs[0])
+	}
+	if e, ok := errs[1].(*hostess.MalformedLineError); !ok || e.Line != 5 || e.Text != "10.0.0.4" {
+		t.Errorf("Expected malformed line 5, found %#v", errs[1])
+	}
+	if e, ok := errs[2].(*hostess.DuplicateError); !ok || e.Line != 6 || e.FirstLine != 2 {
+		t.Errorf("Expected duplicate on line 6, found %#v", errs[2])
+	}
+
+	expected := hostfile.Path + ":7: conflicting entries for api.local (10.0.0.2 vs 10.0.0.3, first seen at line 2)"
+	if _, ok := errs[3].(*hostess.ConflictError); !ok || errs[3].Error() != expected {
+		t.Errorf("Expected %q, found %q", expected, errs[3])
+	}
+
+	// Invalid lines are kept so we don't throw away anything we don't
+	// understand
+	if hostfile.Lines[3].Kind != hostess.InvalidLine {
+		t.Errorf("Expected line 4 to be invalid, found kind %d", hostfile.Lines[3].Kind)
+	}
+	if hostfile.Lines[2].Kind != hostess.CommentLine {
+		t.Errorf("Expected line 3 to be a comment, found kind %d", hostfile.Lines[2].Kind)
+	}
+}
+
+func TestParseMacOSDefault(t *testing.T) {
+	const data = `##
+# Host Database
+#
+# localhost is used to configure the loopback interface
+# when the system is booting.  Do not change this entry.
+##
+
+127.0.0.1       localhost
+255.255.255.255 broadcasthost
+::1             localhost
+fe80::1%lo0     localhost
+`
+	hostfile := ParseHostfile(t, data)
+
+	if !hostfile.Hosts.Contains(hostess.MustHostname("localhost", "fe80::1%lo0", true)) {
+		t.Error("Expected to find fe80::1%lo0 localhost")
+	}
+	if !hostfile.Hosts.Contains(hostess.MustHostname("localhost", "::1", true)) {
+		t.Error("Expected to find ::1 localhost")
+	}
+
+	output := string(hostfile.Format())
+	if output != data {
+		t.Errorf("Expected hostfile to round-trip: %s", Diff(data, output))
+	}
+
+	hostfile.DiscardLayout()
+	expected := `127.0.0.1 localhost
+255.255.255.255 broadcasthost
+::1 localhost
+fe80::1%lo0 localhost
+`
+	output = string(hostfile.Hosts.FormatLinux())
+	if output != expected {
+		t.Errorf("Unexpected output: %s", Diff(expected, output))
+	}
+}
@@ -0,0 +1,125 @@
+//go:build !windows
+// +build !windows
+
+package hostess
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// writeHostsFile replaces the contents of the hosts file at path with data.
+//
+// On Unix we write to a temp file in the same directory, fsync it, copy the
+// original file's mode and owner, and rename it over the original. This way a
+// crash, full disk, or kill signal leaves either the old or the new file in
+// place, never a truncated one. Finally we fsync the directory so the rename
+// itself is durable.
+//
+// Some environments don't allow renaming over the hosts file. For example,
+// Docker bind-mounts /etc/hosts into containers, so rename fails with EBUSY.
+// In these cases we fall back to rewriting the file in place.
+func writeHostsFile(path string, data []byte) error {
+	// If the hosts file is a symlink we want to replace the file it points
+	// to, not the link itself.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	temp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".hostess-*")
+	if err != nil {
+		if os.IsPermission(err) {
+			return writeInPlace(path, data)
+		}
+		return err
+	}
+	// After a successful rename this is a no-op.
+	defer os.Remove(temp.Name())
+
+	if err := writeTemp(temp, data, info); err != nil {
+		if os.IsPermission(err) {
+			return writeInPlace(path, data)
+		}
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+			return writeInPlace(path, data)
+		}
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// writeTemp writes data to temp and makes it durable, matching the mode and
+// owner of the file described by info (if it exists). temp is always closed.
+func writeTemp(temp *os.File, data []byte, info os.FileInfo) error {
+	defer temp.Close()
+
+	if _, err := temp.Write(data); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info != nil {
+		mode = info.Mode().Perm()
+	}
+	if err := temp.Chmod(mode); err != nil {
+		return err
+	}
+
+	if info != nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := temp.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+
+	return temp.Close()
+}
+
+// writeInPlace truncates and rewrites the file at path. This is not atomic,
+// so we only use it when we can't rename over the file.
+func writeInPlace(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// syncDir fsyncs a directory so that a rename inside it is durable.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -0,0 +1,76 @@
+//go:build !windows
+// +build !windows
+
+package hostess_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestSaveReplacesAtomically(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostess-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "hosts")
+	if err := ioutil.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	// Save should replace the file the link points to, not the link.
+	link := filepath.Join(dir, "hosts-link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = link
+	if err := hostfile.Read(); err != nil {
+		t.Fatal(err)
+	}
+	hostfile.Parse()
+	hostfile.Hosts.Add(hostess.MustHostname("devsite", "127.0.0.1", true))
+	if err := hostfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "127.0.0.1 localhost\n127.0.0.1 devsite\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected output: %s", Diff(expected, string(data)))
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Expected symlink to be left in place")
+	}
+
+	info, err = os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640 to be preserved, found %o", info.Mode().Perm())
+	}
+
+	// The temp file should have been renamed, leaving nothing behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected only hosts and hosts-link in %s, found %d files", dir, len(files))
+	}
+}
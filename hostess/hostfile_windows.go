@@ -0,0 +1,26 @@
+package hostess
+
+import (
+	"os"
+)
+
+// writeHostsFile replaces the contents of the hosts file at path with data.
+//
+// Windows wants the file to be truncated before it's opened. Then we re-write
+// the entire file contents. Truncating up front is risky but I don't know of
+// a better way to do it; an earlier version of the program used an atomic
+// rename here but that did not work on Windows so it was rolled back.
+func writeHostsFile(path string, data []byte) error {
+	if err := os.Truncate(path, 0); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
 	"runtime"
 	"sort"
 	"strings"
@@ -66,18 +67,21 @@ func (h Hostlist) Less(A, B int) bool {
 		return false
 	}
 
-	// Sort "localhost" at the top
-	if h[A].Domain == "localhost" {
+	// Sort "localhost" at the top. If both are localhost (e.g. ::1 and
+	// fe80::1%lo0) we'll sort them by IP below.
+	keyA, keyB := DomainKey(h[A].Domain), DomainKey(h[B].Domain)
+	if keyA == "localhost" && keyB != "localhost" {
 		return true
 	}
-	if h[B].Domain == "localhost" {
+	if keyB == "localhost" && keyA != "localhost" {
 		return false
 	}
 
 	// Compare the the IP addresses (byte array)
 	// We want to push 127. to the top so we're going to mark it zero.
-	surrogateA := MakeSurrogateIP(h[A].IP)
-	surrogateB := MakeSurrogateIP(h[B].IP)
+	// We use the 16-byte form of both IPs so they're the same length.
+	surrogateA := MakeSurrogateIP(h[A].IP).To16()
+	surrogateB := MakeSurrogateIP(h[B].IP).To16()
 	if !surrogateA.Equal(surrogateB) {
 		for charIndex := range surrogateA {
 			// A and B's IPs differ at this index, and A is less. A wins!
@@ -93,36 +97,22 @@ func (h Hostlist) Less(A, B int) bool {
 		// to the domain sorting section.
 	}
 
-	// Prep for sorting by domain name
-	aLength := len(h[A].Domain)
-	bLength := len(h[B].Domain)
-	max := aLength
-	if bLength > max {
-		max = bLength
+	// Zoned addresses sort after the same address without a zone
+	if h[A].IP.Equal(h[B].IP) && h[A].Zone != h[B].Zone {
+		return h[A].Zone < h[B].Zone
 	}
 
-	// Sort domains alphabetically
-	// TODO: This works best if domains are lowercased. However, we do not
-	// enforce lowercase because of UTF-8 domain names, which may be broken by
-	// case folding. There is a way to do this correctly but it's complicated
-	// so I'm not going to do it right now.
-	for charIndex := 0; charIndex < max; charIndex++ {
-		// This index is longer than A, so A is shorter. A wins!
-		if charIndex >= aLength {
-			return true
-		}
-		// This index is longer than B, so B is shorter. A loses!
-		if charIndex >= bLength {
-			return false
-		}
-		// A and B differ at this index and A is less. A wins!
-		if h[A].Domain[charIndex] < h[B].Domain[charIndex] {
-			return true
-		}
-		// A and B differ at this index and B is less. A loses!
-		if h[A].Domain[charIndex] > h[B].Domain[charIndex] {
-			return false
-		}
+	// Sort domains alphabetically, ignoring case and trailing dots. If the
+	// domains only differ in spelling we'll compare them as written so the
+	// order is still deterministic.
+	if keyA != keyB {
+		return keyA < keyB
+	}
+	if h[A].Domain != h[B].Domain {
+		return h[A].Domain < h[B].Domain
+	}
+	if h[A].Profile != h[B].Profile {
+		return h[A].Profile < h[B].Profile
 	}
 
 	// If we got here then A and B are the same -- by definition A is not Less
@@ -141,7 +131,8 @@ func (h Hostlist) Swap(i, j int) {
 // 	1. localhost comes before other hostnames
 // 	2. IPv4 comes before IPv6
 // 	3. IPs are sorted in numerical order
-// 	4. The remaining hostnames are sorted in lexicographical order
+// 	4. The remaining hostnames are sorted in lexicographical order, ignoring
+// 	   case and trailing dots
 func (h *Hostlist) Sort() {
 	sort.Sort(*h)
 }
@@ -156,10 +147,11 @@ func (h *Hostlist) Contains(b *Hostname) bool {
 	return false
 }
 
-// ContainsDomain returns true if a Hostname in this Hostlist matches domain
+// ContainsDomain returns true if a Hostname in this Hostlist matches domain,
+// ignoring case and a trailing dot
 func (h *Hostlist) ContainsDomain(domain string) bool {
 	for _, hostname := range *h {
-		if hostname.Domain == domain {
+		if hostname.EqualDomain(domain) {
 			return true
 		}
 	}
@@ -176,20 +168,80 @@ func (h *Hostlist) ContainsIP(IP net.IP) bool {
 	return false
 }
 
+// hostnameKey identifies a Hostname within a Hostlist. A Hostlist may only
+// contain one Hostname for each key: the domain, IP version, and zone (if
+// any). Since a zoned address only applies to one network interface, it does
+// not conflict with an unzoned address for the same domain. Domains are
+// compared using DomainKey.
+//
+// The profile is also part of the key, so each profile may have its own entry
+// for a domain.
+func hostnameKey(hostname *Hostname) string {
+	key := versionKey(hostname)
+	if hostname.Profile != "" {
+		key += "@" + hostname.Profile
+	}
+	return key
+}
+
+// versionKey is like hostnameKey, but without the profile. Only one enabled
+// Hostname in a Hostlist may have each versionKey; see disableConflicts.
+func versionKey(hostname *Hostname) string {
+	key := fmt.Sprintf("%s/%d", DomainKey(hostname.Domain), ipVersion(hostname))
+	if hostname.Zone != "" {
+		key += "%" + hostname.Zone
+	}
+	return key
+}
+
+// indexOfKey returns the index of the Hostname matching key (see hostnameKey)
+// or -1 if it is not found.
+func (h *Hostlist) indexOfKey(key string) int {
+	for index, hostname := range *h {
+		if hostnameKey(hostname) == key {
+			return index
+		}
+	}
+	return -1
+}
+
 // Add a new Hostname to this hostlist. Add uses some merging logic in the
 // event it finds duplicated hostnames. In the case of a conflict (incompatible
 // entries) the last write wins. In the case of duplicates, duplicates will be
 // removed and the remaining entry will be enabled if any of the duplicates was
 // enabled.
 //
-// Both duplicate and conflicts return errors so you are aware of them, but you
-// don't necessarily need to do anything about the error.
+// Domains are matched ignoring case and trailing dots (see DomainKey), and
+// the existing entry's spelling is kept.
+//
+// Each profile may have its own entry for a domain, but only one of them can
+// be enabled. If the new Hostname is enabled, Add disables the entries for the
+// same domain and IP version in other profiles (or not in a profile) and
+// returns a *ConflictError.
+//
+// Both duplicate and conflicts return errors (*DuplicateError and
+// *ConflictError) so you are aware of them, but you don't necessarily need to
+// do anything about the error.
 func (h *Hostlist) Add(input *Hostname) error {
-	newHostname, err := NewHostname(input.Domain, input.IP.String(), input.Enabled)
+	// input may have come from the hosts file, so we only check its domain
+	// leniently. NewHostname has already checked any new names.
+	newHostname, err := newHostname(input.Domain, input.FormatIP(), input.Enabled, true)
 	if err != nil {
 		return err
 	}
+	newHostname.Profile = input.Profile
+	newHostname.Expires = input.Expires
+	newHostname.Comment = input.Comment
+	newHostname.Metadata = input.Metadata
+
+	var profileErr error
+	if newHostname.Enabled {
+		profileErr = h.disableConflicts(newHostname)
+	}
 	for index, found := range *h {
+		if hostnameKey(found) != hostnameKey(newHostname) {
+			continue
+		}
 		if found.Equal(newHostname) {
 			// If either hostname is enabled we will set the existing one to
 			// enabled state. That way if we add a hostname from the end of a
@@ -197,16 +249,36 @@ func (h *Hostlist) Add(input *Hostname) error {
 			// the original one will stick. We still error in this case so the
 			// user can see that there is a duplicate.
 			(*h)[index].Enabled = found.Enabled || newHostname.Enabled
-			return fmt.Errorf("duplicate hostname entry for %s -> %s",
-				newHostname.Domain, newHostname.IP)
-		} else if found.Domain == newHostname.Domain && found.IPv6 == newHostname.IPv6 {
-			(*h)[index] = newHostname
-			return fmt.Errorf("conflicting hostname entries for %s -> %s and -> %s",
-				newHostname.Domain, newHostname.IP, found.IP)
+			// The expiry time is not part of the entry's identity, so the
+			// last one wins. This way you can add an entry again to change
+			// when it expires.
+			(*h)[index].Expires = newHostname.Expires
+			keepComment(newHostname, found)
+			(*h)[index].Comment = newHostname.Comment
+			(*h)[index].Metadata = newHostname.Metadata
+			return &DuplicateError{Hostname: newHostname}
 		}
+		// Keep the spelling the domain already had, e.g. if API.local is
+		// replaced by api.local we'll still write API.local.
+		newHostname.Domain = found.Domain
+		keepComment(newHostname, found)
+		(*h)[index] = newHostname
+		return &ConflictError{Previous: found, Hostname: newHostname}
 	}
 	*h = append(*h, newHostname)
-	return nil
+	return profileErr
+}
+
+// keepComment copies the Comment and Metadata from found to replacement if
+// replacement doesn't have its own, so adding an entry again without a comment
+// doesn't throw the comment away.
+func keepComment(replacement, found *Hostname) {
+	if replacement.Comment == "" {
+		replacement.Comment = found.Comment
+	}
+	if len(replacement.Metadata) == 0 {
+		replacement.Metadata = found.Metadata
+	}
 }
 
 // IndexOf will indicate the index of a Hostname in Hostlist, or -1 if it is
@@ -229,7 +301,7 @@ func (h *Hostlist) IndexOfDomainV(domain string, version int) int {
 		panic(ErrInvalidVersionArg)
 	}
 	for index, hostname := range *h {
-		if hostname.Domain == domain && hostname.IPv6 == (version == 6) {
+		if hostname.EqualDomain(domain) && hostname.IPv6 == (version == 6) {
 			return index
 		}
 	}
@@ -253,42 +325,74 @@ func (h *Hostlist) RemoveDomain(domain string) int {
 	return h.RemoveDomainV(domain, 4) + h.RemoveDomainV(domain, 6)
 }
 
-// RemoveDomainV removes a Hostname entry matching the domain and IP version.
+// RemoveDomainV removes Hostname entries matching the domain and IP version.
+// There is normally only one, but there may also be entries with zoned IPv6
+// addresses. Returns the number of entries removed.
 func (h *Hostlist) RemoveDomainV(domain string, version int) int {
-	return h.Remove(h.IndexOfDomainV(domain, version))
+	removed := 0
+	for h.Remove(h.IndexOfDomainV(domain, version)) > 0 {
+		removed++
+	}
+	return removed
 }
 
-// Enable will change any Hostnames matching name to be enabled.
+// Enable will change a Hostname matching name to be enabled.
+//
+// If there are entries for name in more than one profile, only one of them can
+// be enabled. Enable picks the one that is already enabled, or else the one
+// that is not in a profile, and disables the entries in other profiles.
 func (h *Hostlist) Enable(name string) error {
-	for _, hostname := range *h {
-		if hostname.Domain == name {
-			hostname.Enabled = true
-			return nil
-		}
-	}
-	return ErrHostnameNotFound
+	return h.enable(func(hostname *Hostname) bool {
+		return hostname.EqualDomain(name)
+	})
 }
 
 // EnableV will change a Hostname matching domain and IP version to be enabled.
+// Like Enable, entries for the same domain in other profiles are disabled.
 //
 // This function will panic if IP version is not 4 or 6.
 func (h *Hostlist) EnableV(domain string, version int) error {
 	if version != 4 && version != 6 {
 		return ErrInvalidVersionArg
 	}
+	return h.enable(func(hostname *Hostname) bool {
+		return hostname.EqualDomain(domain) && hostname.IPv6 == (version == 6)
+	})
+}
+
+// enable enables one of the Hostnames for which match returns true, and
+// disables its conflicts. See Enable for which one we pick.
+func (h *Hostlist) enable(match func(*Hostname) bool) error {
+	var target *Hostname
 	for _, hostname := range *h {
-		if hostname.Domain == domain && hostname.IPv6 == (version == 6) {
-			hostname.Enabled = true
-			return nil
+		if !match(hostname) {
+			continue
+		}
+		switch {
+		case target == nil:
+			target = hostname
+		case target.Enabled != hostname.Enabled:
+			if hostname.Enabled {
+				target = hostname
+			}
+		case target.Profile != "" && hostname.Profile == "":
+			target = hostname
 		}
 	}
-	return ErrHostnameNotFound
+	if target == nil {
+		return ErrHostnameNotFound
+	}
+
+	target.Enabled = true
+	// Disabling the other entries is what we want, not a problem
+	h.disableConflicts(target)
+	return nil
 }
 
 // Disable will change any Hostnames matching name to be disabled.
 func (h *Hostlist) Disable(name string) error {
 	for _, hostname := range *h {
-		if hostname.Domain == name {
+		if hostname.EqualDomain(name) {
 			hostname.Enabled = false
 			return nil
 		}
@@ -304,7 +408,7 @@ func (h *Hostlist) DisableV(domain string, version int) error {
 		return ErrInvalidVersionArg
 	}
 	for _, hostname := range *h {
-		if hostname.Domain == domain && hostname.IPv6 == (version == 6) {
+		if hostname.EqualDomain(domain) && hostname.IPv6 == (version == 6) {
 			hostname.Enabled = false
 			return nil
 		}
@@ -325,7 +429,7 @@ func (h *Hostlist) FilterByIP(IP net.IP) (hostnames []*Hostname) {
 // FilterByDomain filters the list of hostnames by Domain.
 func (h *Hostlist) FilterByDomain(domain string) (hostnames []*Hostname) {
 	for _, hostname := range *h {
-		if hostname.Domain == domain {
+		if hostname.EqualDomain(domain) {
 			hostnames = append(hostnames, hostname)
 		}
 	}
@@ -342,13 +446,43 @@ func (h *Hostlist) FilterByDomainV(domain string, version int) (hostnames []*Hos
 		panic(ErrInvalidVersionArg)
 	}
 	for _, hostname := range *h {
-		if hostname.Domain == domain && hostname.IPv6 == (version == 6) {
+		if hostname.EqualDomain(domain) && hostname.IPv6 == (version == 6) {
 			hostnames = append(hostnames, hostname)
 		}
 	}
 	return
 }
 
+// FilterByDomainPattern filters the list of hostnames by a shell pattern like
+// *.example.com, ignoring case and trailing dots. See path.Match for the
+// syntax. Returns an error if the pattern is malformed.
+func (h *Hostlist) FilterByDomainPattern(pattern string) (Hostlist, error) {
+	hostnames := Hostlist{}
+	pattern = DomainKey(pattern)
+	for _, hostname := range *h {
+		matched, err := path.Match(pattern, DomainKey(hostname.Domain))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames, nil
+}
+
+// FilterByNetwork filters the list of hostnames by IP range, e.g. the result
+// of net.ParseCIDR("10.0.0.0/8").
+func (h *Hostlist) FilterByNetwork(network *net.IPNet) Hostlist {
+	hostnames := Hostlist{}
+	for _, hostname := range *h {
+		if network.Contains(hostname.IP) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
+
 // GetUniqueIPs extracts an ordered list of unique IPs from the Hostlist.
 // This calls Sort() internally.
 func (h *Hostlist) GetUniqueIPs() []net.IP {
@@ -382,15 +516,28 @@ func (h *Hostlist) FormatLinux() []byte {
 	out := bytes.Buffer{}
 
 	// We want to output one line of hostnames per IP, so first we get that
-	// list of IPs and iterate.
-	for _, IP := range h.GetUniqueIPs() {
+	// list of IPs and iterate. We group by the formatted IP rather than using
+	// GetUniqueIPs so zoned addresses get their own lines. Hostnames with
+	// different inline comments (e.g. expiry times) also need their own lines.
+	seen := make(map[string]bool)
+	for _, current := range *h {
+		IP := current.FormatIP()
+		annotation := current.FormatComment()
+		if seen[IP+" "+annotation] {
+			continue
+		}
+		seen[IP+" "+annotation] = true
+
 		// Technically if an IP has some disabled hostnames we'll show two
 		// lines, one starting with a comment (#).
 		enabledIPs := []string{}
 		disabledIPs := []string{}
 
 		// For this IP, get all hostnames that match and iterate over them.
-		for _, hostname := range h.FilterByIP(IP) {
+		for _, hostname := range *h {
+			if hostname.FormatIP() != IP || hostname.FormatComment() != annotation {
+				continue
+			}
 			// If it's enabled, put it in the enabled bucket (likewise for
 			// disabled hostnames)
 			if hostname.Enabled {
@@ -402,12 +549,17 @@ func (h *Hostlist) FormatLinux() []byte {
 
 		// Finally, if the bucket contains anything, concatenate it all
 		// together and append it to the output. Also add a newline.
+		comment := ""
+		if annotation != "" {
+			comment = " # " + annotation
+		}
+
 		if len(enabledIPs) > 0 {
-			out.WriteString(fmt.Sprintf("%s %s\n", IP.String(), strings.Join(enabledIPs, " ")))
+			out.WriteString(fmt.Sprintf("%s %s%s\n", IP, strings.Join(enabledIPs, " "), comment))
 		}
 
 		if len(disabledIPs) > 0 {
-			out.WriteString(fmt.Sprintf("# %s %s\n", IP.String(), strings.Join(disabledIPs, " ")))
+			out.WriteString(fmt.Sprintf("# %s %s%s\n", IP, strings.Join(disabledIPs, " "), comment))
 		}
 	}
 
@@ -453,17 +605,23 @@ func (h *Hostlist) Dump() ([]byte, error) {
 	return json.MarshalIndent(h, "", "  ")
 }
 
-// Apply imports all entries from the JSON input to this Hostlist
+// Apply imports all entries from the JSON input to this Hostlist, and removes
+// entries whose state is absent. To also remove entries that are not in the
+// input, use Sync. Names that are already in the Hostlist are accepted as they
+// are, but new names must be valid (see NewHostname).
 func (h *Hostlist) Apply(jsonbytes []byte) error {
-	var hostnames Hostlist
-	err := json.Unmarshal(jsonbytes, &hostnames)
+	hostnames, absent, err := decodeApply(jsonbytes)
 	if err != nil {
 		return err
 	}
+	if err := h.checkNewNames(hostnames); err != nil {
+		return err
+	}
 
 	for _, hostname := range hostnames {
 		h.Add(hostname)
 	}
+	h.removeAbsent(absent)
 
 	return nil
 }
@@ -47,6 +47,64 @@ func TestAddConflict(t *testing.T) {
 	}
 }
 
+func TestDomainIdentity(t *testing.T) {
+	list := hostess.NewHostlist()
+	if err := list.Add(hostess.MustHostname("API.local", "10.0.0.1", true)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resolvers treat these as the same name, so they are duplicates
+	for _, domain := range []string{"api.local", "api.local.", "Api.Local."} {
+		err := list.Add(hostess.MustHostname(domain, "10.0.0.1", true))
+		if _, ok := err.(*hostess.DuplicateError); !ok {
+			t.Errorf("Expected %s to be a duplicate, found %v", domain, err)
+		}
+		if !list.ContainsDomain(domain) {
+			t.Errorf("Expected to find %s", domain)
+		}
+		if list.IndexOfDomainV(domain, 4) != 0 {
+			t.Errorf("Expected to find %s at index 0", domain)
+		}
+	}
+	if len(*list) != 1 {
+		t.Fatalf("Expected 1 hostname, found %d", len(*list))
+	}
+	// We keep the spelling we saw first
+	if (*list)[0].Domain != "API.local" {
+		t.Errorf("Expected API.local, found %s", (*list)[0].Domain)
+	}
+
+	err := list.Add(hostess.MustHostname("api.local.", "10.0.0.2", true))
+	if _, ok := err.(*hostess.ConflictError); !ok {
+		t.Errorf("Expected a conflict, found %v", err)
+	}
+	if (*list)[0].Domain != "API.local" || !(*list)[0].EqualIP(net.ParseIP("10.0.0.2")) {
+		t.Errorf("Expected API.local -> 10.0.0.2, found %s", (*list)[0].FormatHuman())
+	}
+
+	if err := list.Disable("API.LOCAL"); err != nil {
+		t.Error(err)
+	}
+	if list.RemoveDomain("api.local") != 1 {
+		t.Error("Expected to remove api.local.")
+	}
+}
+
+func TestSortIgnoresCase(t *testing.T) {
+	hosts := hostess.NewHostlist()
+	hosts.Add(hostess.MustHostname("Zebra.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("apple.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("Mango.local", "10.0.0.1", true))
+	hosts.Add(hostess.MustHostname("LOCALHOST", "10.0.0.1", true))
+
+	hosts.Sort()
+
+	CheckIndexDomain(t, 0, "LOCALHOST", hosts)
+	CheckIndexDomain(t, 1, "apple.local", hosts)
+	CheckIndexDomain(t, 2, "Mango.local", hosts)
+	CheckIndexDomain(t, 3, "Zebra.local", hosts)
+}
+
 func TestMakeSurrogateIP(t *testing.T) {
 	original := net.ParseIP("127.0.0.1")
 	expected1 := net.ParseIP("0.0.0.1")
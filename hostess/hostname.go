@@ -1,28 +1,29 @@
 package hostess
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
-	"regexp"
 	"strings"
+	"time"
 )
 
-var ipv4Pattern = regexp.MustCompile(`^(?:[0-9]{1,3}\.){3}[0-9]{1,3}$`)
-var ipv6Pattern = regexp.MustCompile(`^[(a-fA-F0-9){1-4}:]+$`)
-
-// LooksLikeIPv4 returns true if the IP looks like it's IPv4. This does not
-// validate whether the string is a valid IP address.
+// LooksLikeIPv4 returns true if ip is a valid IPv4 address in dotted decimal
+// form, e.g. 127.0.0.1.
 func LooksLikeIPv4(ip string) bool {
-	return ipv4Pattern.MatchString(ip)
+	return !strings.Contains(ip, ":") && net.ParseIP(ip) != nil
 }
 
-// LooksLikeIPv6 returns true if the IP looks like it's IPv6. This does not
-// validate whether the string is a valid IP address.
+// LooksLikeIPv6 returns true if ip is a valid IPv6 address, optionally with a
+// zone, e.g. ::1 or fe80::1%lo0. Note that IPv4-mapped IPv6 addresses like
+// ::ffff:10.0.0.1 are valid IPv6 addresses, but NewHostname treats them as
+// IPv4.
 func LooksLikeIPv6(ip string) bool {
 	if !strings.Contains(ip, ":") {
 		return false
 	}
-	return ipv6Pattern.MatchString(ip)
+	_, _, err := parseIP(ip)
+	return err == nil
 }
 
 // Hostname represents a hosts file entry, including a Domain, IP, whether the
@@ -32,21 +33,82 @@ func LooksLikeIPv6(ip string) bool {
 // Hostname fields except through the Hostlist's aggregate methods. Doing so
 // can cause unexpected behavior. Instead, use Hostlist's Add, Remove, Enable,
 // and Disable methods.
+//
+// Link-local IPv6 addresses may include a zone (the interface they belong to)
+// like fe80::1%lo0. net.IP can't represent this, so the zone is kept in Zone.
+// Use FormatIP to get the full address.
+//
+// Profile is the name of the profile the Hostname belongs to, if any. See
+// Hostlist.EnableProfile.
+//
+// Expires is when the Hostname should be removed by Hostlist.RemoveExpired.
+// The zero time means it never expires.
+//
+// Comment is a note about why the Hostname exists, and Metadata holds any
+// other information you want to keep with it, like who added it. Expires,
+// Comment, and Metadata are saved in the hosts file in an inline comment,
+// e.g. # INC-1234 bypass CDN [expires=2026-11-01T00:00:00Z owner=alice]
 type Hostname struct {
-	Domain  string `json:"domain"`
-	IP      net.IP `json:"ip"`
-	Enabled bool   `json:"enabled"`
-	IPv6    bool   `json:"-"`
+	Domain   string            `json:"domain"`
+	IP       net.IP            `json:"ip"`
+	Enabled  bool              `json:"enabled"`
+	IPv6     bool              `json:"-"`
+	Zone     string            `json:"-"`
+	Profile  string            `json:"profile,omitempty"`
+	Expires  time.Time         `json:"expires,omitempty"`
+	Comment  string            `json:"comment,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // NewHostname creates a new Hostname struct and automatically sets the IPv6
-// field based on the IP you pass in.
+// and Zone fields based on the IP you pass in. The IP must be a valid IPv4 or
+// IPv6 address. Equivalent ways of writing the same IPv6 address (e.g. 0:0::1
+// and ::1) are treated the same way, and IPv4-mapped IPv6 addresses like
+// ::ffff:10.0.0.1 are treated as the equivalent IPv4 address.
+//
+// The domain must be a valid hostname (see ValidateDomain), or if
+// HOSTESS_NAMES=lenient, something that can at least be written to a hosts
+// file (see ValidateDomainLenient). Unicode domains are converted to punycode.
 func NewHostname(domain, ip string, enabled bool) (*Hostname, error) {
-	if !LooksLikeIPv4(ip) && !LooksLikeIPv6(ip) {
-		return nil, fmt.Errorf("Unable to parse IP address %q", ip)
+	return newHostname(domain, ip, enabled, IsLenient())
+}
+
+// newHostname is like NewHostname, but checks the domain with
+// ValidateDomainLenient if lenient is true. We use it for names that are
+// already in the hosts file, which we need to be able to read even if
+// NewHostname would reject them.
+func newHostname(domain, ip string, enabled, lenient bool) (*Hostname, error) {
+	IP, zone, err := parseIP(ip)
+	if err != nil {
+		return nil, err
+	}
+	domain, err = normalizeDomain(domain, lenient)
+	if err != nil {
+		return nil, err
+	}
+	return &Hostname{Domain: domain, IP: IP, Enabled: enabled, IPv6: IP.To4() == nil, Zone: zone}, nil
+}
+
+// parseIP parses an IPv4 or IPv6 address, and the zone if it is an IPv6
+// address with a zone. parseIP returns *InvalidIPError if ip is not valid.
+func parseIP(ip string) (net.IP, string, error) {
+	address, zone := ip, ""
+	index := strings.LastIndex(ip, "%")
+	if index > -1 {
+		address, zone = ip[:index], ip[index+1:]
+	}
+
+	IP := net.ParseIP(address)
+	if IP == nil {
+		return nil, "", &InvalidIPError{IP: ip}
+	}
+
+	// Only IPv6 addresses have zones, and if there's a % there must be a zone
+	if index > -1 && (zone == "" || IP.To4() != nil) {
+		return nil, "", &InvalidIPError{IP: ip}
 	}
-	IP := net.ParseIP(ip)
-	return &Hostname{domain, IP, enabled, LooksLikeIPv6(ip)}, nil
+
+	return IP, zone, nil
 }
 
 // MustHostname calls NewHostname but panics if there is an error parsing it.
@@ -58,14 +120,20 @@ func MustHostname(domain, ip string, enabled bool) *Hostname {
 	return hostname
 }
 
-// Equal compares two Hostnames. Note that only the Domain and IP fields are
-// compared because Enabled is transient state, and IPv6 should be set
-// automatically based on IP.
+// Equal compares two Hostnames. Note that only the Domain, IP, and Zone fields
+// are compared because Enabled is transient state, and IPv6 should be set
+// automatically based on IP. Domains are compared with EqualDomain.
 func (h *Hostname) Equal(n *Hostname) bool {
-	return h.Domain == n.Domain && h.IP.Equal(n.IP)
+	return h.EqualDomain(n.Domain) && h.IP.Equal(n.IP) && h.Zone == n.Zone
+}
+
+// EqualDomain returns true if domain is the same as this Hostname's Domain,
+// ignoring case and a trailing dot. See DomainKey.
+func (h *Hostname) EqualDomain(domain string) bool {
+	return DomainKey(h.Domain) == DomainKey(domain)
 }
 
-// EqualIP compares an IP against this Hostname.
+// EqualIP compares an IP against this Hostname. The Zone is ignored.
 func (h *Hostname) EqualIP(ip net.IP) bool {
 	return h.IP.Equal(ip)
 }
@@ -75,17 +143,71 @@ func (h *Hostname) IsValid() bool {
 	return h.Domain != "" && h.IP != nil
 }
 
+// FormatIP outputs the IP, including the zone if there is one. E.g.
+// fe80::1%lo0
+func (h *Hostname) FormatIP() string {
+	if h.Zone != "" {
+		return h.IP.String() + "%" + h.Zone
+	}
+	return h.IP.String()
+}
+
 // Format outputs the Hostname as you'd see it in a hosts file, with a comment
 // if it is disabled. E.g.
 // # 127.0.0.1 blah.example.com
 func (h *Hostname) Format() string {
-	r := fmt.Sprintf("%s %s", h.IP.String(), h.Domain)
+	r := fmt.Sprintf("%s %s", h.FormatIP(), h.Domain)
 	if !h.Enabled {
 		r = "# " + r
 	}
+	if comment := h.FormatComment(); comment != "" {
+		r += " # " + comment
+	}
 	return r
 }
 
+// FormatComment outputs the inline comment for the Hostname as you'd see it
+// in a hosts file, without the leading #. E.g.
+// INC-1234 bypass CDN [expires=2026-11-01T00:00:00Z owner=alice]
+// If the Hostname has no Comment, Metadata, or expiry time FormatComment
+// returns a blank string.
+func (h *Hostname) FormatComment() string {
+	fields := map[string]string{}
+	for key, value := range h.Metadata {
+		fields[key] = value
+	}
+	if !h.Expires.IsZero() {
+		fields[expiresKey] = h.Expires.UTC().Format(time.RFC3339)
+	}
+	return TrimWS(h.Comment + " " + formatAnnotation(fields))
+}
+
+// FormatMetadata outputs the Hostname's Metadata sorted by key, e.g.
+// [owner=alice ticket=INC-1234], or a blank string if there isn't any.
+func (h *Hostname) FormatMetadata() string {
+	return formatAnnotation(h.Metadata)
+}
+
+// applyComment sets the fields that were parsed from an inline comment.
+func (h *Hostname) applyComment(text string, fields map[string]string) error {
+	h.Comment = text
+	for key, value := range fields {
+		if key != expiresKey {
+			if h.Metadata == nil {
+				h.Metadata = map[string]string{}
+			}
+			h.Metadata[key] = value
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return &MalformedLineError{Reason: fmt.Sprintf("unable to parse expiry time %q", value)}
+		}
+		h.Expires = expires
+	}
+	return nil
+}
+
 // FormatEnabled displays Hostname.Enabled as (On) or (Off)
 func (h *Hostname) FormatEnabled() string {
 	if h.Enabled {
@@ -97,5 +219,76 @@ func (h *Hostname) FormatEnabled() string {
 // FormatHuman outputs the Hostname in a more human-readable format:
 // blah.example.com -> 127.0.0.1 (Off)
 func (h *Hostname) FormatHuman() string {
-	return fmt.Sprintf("%s -> %s %s", h.Domain, h.IP, h.FormatEnabled())
+	return fmt.Sprintf("%s -> %s %s", h.Domain, h.FormatIP(), h.FormatEnabled())
+}
+
+// hostnameJSON is how a Hostname looks in JSON. The IP is a string so it can
+// include the zone.
+type hostnameJSON struct {
+	Domain   string            `json:"domain"`
+	IP       string            `json:"ip"`
+	Enabled  bool              `json:"enabled"`
+	Profile  string            `json:"profile,omitempty"`
+	Expires  string            `json:"expires,omitempty"`
+	Comment  string            `json:"comment,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (h *Hostname) MarshalJSON() ([]byte, error) {
+	expires := ""
+	if !h.Expires.IsZero() {
+		expires = h.Expires.UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(hostnameJSON{h.Domain, h.FormatIP(), h.Enabled, h.Profile, expires, h.Comment, h.Metadata})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The IP is validated the same way
+// as NewHostname, but JSON from dump may include names that are already in the
+// hosts file, so the domain is checked leniently the same way Parse does.
+// Hostlist.Apply checks names that are new to the Hostlist strictly.
+func (h *Hostname) UnmarshalJSON(data []byte) error {
+	var decoded hostnameJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	hostname, err := decoded.hostname(!IsStrict())
+	if err != nil {
+		return err
+	}
+	*h = *hostname
+	return nil
+}
+
+// hostname validates the decoded JSON and creates a Hostname from it. See
+// newHostname for lenient.
+func (d *hostnameJSON) hostname(lenient bool) (*Hostname, error) {
+	hostname, err := newHostname(d.Domain, d.IP, d.Enabled, lenient)
+	if err != nil {
+		return nil, err
+	}
+	if d.Profile != "" {
+		if err := ValidateProfileName(d.Profile); err != nil {
+			return nil, err
+		}
+		hostname.Profile = d.Profile
+	}
+	if d.Expires != "" {
+		expires, err := ParseUntil(d.Expires)
+		if err != nil {
+			return nil, err
+		}
+		hostname.Expires = expires
+	}
+	if err := ValidateComment(d.Comment); err != nil {
+		return nil, err
+	}
+	if err := ValidateMetadata(d.Metadata); err != nil {
+		return nil, err
+	}
+	hostname.Comment = d.Comment
+	if len(d.Metadata) > 0 {
+		hostname.Metadata = d.Metadata
+	}
+	return hostname, nil
 }
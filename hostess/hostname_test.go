@@ -1,7 +1,9 @@
 package hostess_test
 
 import (
+	"encoding/json"
 	"net"
+	"strings"
 	"testing"
 
 	hostess2 "github.com/cbednarski/hostess/hostess"
@@ -113,3 +115,159 @@ func TestFormatEnabled(t *testing.T) {
 		t.Error("Expected hostname to be turned (Off)")
 	}
 }
+
+func TestZonedIPv6(t *testing.T) {
+	hostname, err := hostess2.NewHostname("localhost", "fe80::1%lo0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hostname.IPv6 {
+		t.Error("Expected fe80::1%lo0 to be IPv6")
+	}
+	if hostname.Zone != "lo0" {
+		t.Errorf("Expected zone lo0, found %q", hostname.Zone)
+	}
+	if !hostname.IP.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("Expected IP fe80::1, found %s", hostname.IP)
+	}
+	const expected = "fe80::1%lo0 localhost"
+	if hostname.Format() != expected {
+		t.Errorf("Expected %q, found %q", expected, hostname.Format())
+	}
+
+	other := hostess2.MustHostname("localhost", "fe80::1%en0", true)
+	if hostname.Equal(other) {
+		t.Errorf("%s and %s should not be equal", hostname.FormatIP(), other.FormatIP())
+	}
+
+	for _, invalid := range []string{"fe80::1%", "127.0.0.1%lo0", "%lo0"} {
+		if _, err := hostess2.NewHostname("localhost", invalid, true); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestHostnameJSON(t *testing.T) {
+	hostname := hostess2.MustHostname("localhost", "fe80::1%lo0", true)
+
+	data, err := json.Marshal(hostname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"domain":"localhost","ip":"fe80::1%lo0","enabled":true}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, found %s", expected, data)
+	}
+
+	decoded := &hostess2.Hostname{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(hostname) || !decoded.IPv6 || !decoded.Enabled {
+		t.Errorf("Expected %+v, found %+v", hostname, decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"domain":"localhost","ip":"nope"}`), decoded); err == nil {
+		t.Error("Expected an error for an invalid IP")
+	}
+}
+
+func TestHostnameCommentJSON(t *testing.T) {
+	hostname := hostess2.MustHostname("cdn.example.com", "10.0.0.9", true)
+	hostname.Comment = "INC-1234 bypass CDN"
+	hostname.Metadata = map[string]string{"owner": "alice"}
+
+	data, err := json.Marshal(hostname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"domain":"cdn.example.com","ip":"10.0.0.9","enabled":true,"comment":"INC-1234 bypass CDN","metadata":{"owner":"alice"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, found %s", expected, data)
+	}
+
+	decoded := &hostess2.Hostname{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Comment != hostname.Comment || decoded.Metadata["owner"] != "alice" {
+		t.Errorf("Expected %+v, found %+v", hostname, decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"domain":"a.local","ip":"10.0.0.1","metadata":{"expires":"soon"}}`), decoded); err == nil {
+		t.Error("Expected an error for the reserved expires key")
+	}
+}
+
+func TestCommentRoundTrip(t *testing.T) {
+	// Brackets are fine as long as they don't look like an annotation
+	for _, comment := range []string{"see [docs]", "ticket [id 42]", "[x=1] at the start"} {
+		if err := hostess2.ValidateComment(comment); err != nil {
+			t.Errorf("Expected %q to be valid, found %s", comment, err)
+			continue
+		}
+		hostname := hostess2.MustHostname("a.local", "10.0.0.1", true)
+		hostname.Comment = comment
+		hostname.Metadata = map[string]string{"owner": "alice"}
+		parsed, err := hostess2.ParseLine(hostname.Format())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed[0].Comment != comment || len(parsed[0].Metadata) != 1 {
+			t.Errorf("Expected %q to round-trip, found %q %v", comment, parsed[0].Comment, parsed[0].Metadata)
+		}
+	}
+
+	// These would be read back as an annotation, so they're rejected
+	for _, comment := range []string{"until [expires=friday]", "ticket [id=42]"} {
+		if err := hostess2.ValidateComment(comment); err == nil {
+			t.Errorf("Expected an error for %q", comment)
+		}
+	}
+}
+
+func TestLooksLikeIP(t *testing.T) {
+	check := func(name string, looksLike func(string) bool, ips string, expected bool) {
+		for _, ip := range strings.Fields(ips) {
+			if looksLike(ip) != expected {
+				t.Errorf("Expected %s(%q) to be %t", name, ip, expected)
+			}
+		}
+	}
+
+	check("LooksLikeIPv4", hostess2.LooksLikeIPv4, ipv4Pass, true)
+	check("LooksLikeIPv4", hostess2.LooksLikeIPv4, ipv4Fail, false)
+	check("LooksLikeIPv6", hostess2.LooksLikeIPv6, ipv6Pass, true)
+	check("LooksLikeIPv6", hostess2.LooksLikeIPv6, ipv6Fail, false)
+}
+
+func TestNewHostnameIP(t *testing.T) {
+	for _, ip := range strings.Fields(ipv4Fail + ipv6Fail) {
+		// These are only in the fail lists for the other IP version
+		if ip == "::1" || ip == "127.0.0.1" {
+			continue
+		}
+		if hostname, err := hostess2.NewHostname("example.com", ip, true); err == nil {
+			t.Errorf("Expected %q to be rejected, found %+v", ip, hostname)
+		}
+	}
+
+	// Different ways of writing the same address are equivalent
+	a := hostess2.MustHostname("localhost", "0:0::1", true)
+	b := hostess2.MustHostname("localhost", "::1", true)
+	if !a.Equal(b) || a.FormatIP() != "::1" {
+		t.Errorf("Expected 0:0::1 to be normalized to ::1, found %s", a.FormatIP())
+	}
+
+	// IPv4-mapped addresses are treated as IPv4
+	mapped := hostess2.MustHostname("mapped", "::ffff:10.0.0.1", true)
+	if mapped.IPv6 {
+		t.Error("Expected ::ffff:10.0.0.1 to be treated as IPv4")
+	}
+	if !mapped.Equal(hostess2.MustHostname("mapped", "10.0.0.1", true)) {
+		t.Error("Expected ::ffff:10.0.0.1 to equal 10.0.0.1")
+	}
+	if mapped.Format() != "10.0.0.1 mapped" {
+		t.Errorf("Expected ::ffff:10.0.0.1 to be formatted as IPv4, found %q", mapped.Format())
+	}
+}
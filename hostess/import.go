@@ -0,0 +1,363 @@
+package hostess
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The Parse* functions in this file read hosts entries from other programs'
+// configuration, like the files the Format* functions in export.go write.
+// Only A and AAAA records (and their equivalents) are imported, and every
+// Hostname is enabled. Other record types are skipped, but lines that can't be
+// parsed or imported are returned as errors with their path and line number,
+// usually a MalformedLineError.
+
+// scanLines calls fn with each line in data and its line number
+func scanLines(data []byte, fn func(number int, text string)) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	number := 0
+	for scanner.Scan() {
+		number++
+		fn(number, scanner.Text())
+	}
+}
+
+// importHostname creates an enabled Hostname for domain and ip. A trailing
+// dot on domain is dropped since hosts files don't use them.
+func importHostname(domain, ip string) (*Hostname, error) {
+	return NewHostname(strings.TrimSuffix(domain, "."), ip, true)
+}
+
+// ParseDnsmasq reads hosts entries from dnsmasq configuration: address lines
+// like address=/api.local/10.0.0.1 and host-record lines like
+// host-record=api.local,10.0.0.1,fd00::1. Other options are ignored.
+func ParseDnsmasq(path string, data []byte) (Hostlist, []error) {
+	hostnames := Hostlist{}
+	errs := []error{}
+	add := func(number int, text, domain, ip string) {
+		hostname, err := importHostname(domain, ip)
+		if err != nil {
+			errs = append(errs, locateError(err, path, number, text, 0))
+			return
+		}
+		hostnames = append(hostnames, hostname)
+	}
+
+	scanLines(data, func(number int, text string) {
+		line := TrimWS(text)
+		switch {
+		case strings.HasPrefix(line, "address="):
+			fields := strings.Split(strings.TrimPrefix(line, "address="), "/")
+			if len(fields) < 3 || fields[0] != "" {
+				errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: line, Reason: "expected address=/domain/ip"})
+				return
+			}
+			ip := fields[len(fields)-1]
+			// address=/domain/ and address=/domain/# don't map to an IP
+			if ip == "" || ip == "#" {
+				return
+			}
+			for _, domain := range fields[1 : len(fields)-1] {
+				if domain == "#" {
+					errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: line, Reason: "unable to import an address for every domain (#)"})
+					continue
+				}
+				add(number, line, domain, ip)
+			}
+
+		case strings.HasPrefix(line, "host-record="):
+			var domains, ips []string
+			for index, field := range strings.Split(strings.TrimPrefix(line, "host-record="), ",") {
+				field = TrimWS(field)
+				switch {
+				case LooksLikeIPv4(field) || LooksLikeIPv6(field):
+					ips = append(ips, field)
+				case index > 0 && isNumber(field):
+					// The TTL
+				default:
+					domains = append(domains, field)
+				}
+			}
+			if len(domains) == 0 || len(ips) == 0 {
+				errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: line, Reason: "expected host-record=domain,ip"})
+				return
+			}
+			for _, domain := range domains {
+				for _, ip := range ips {
+					add(number, line, domain, ip)
+				}
+			}
+		}
+	})
+	return hostnames, errs
+}
+
+// isNumber returns true if s is a non-negative integer, like a TTL
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+// ttlUnits are the units BIND accepts in TTLs, like 1h30m or 1W, in seconds
+var ttlUnits = map[byte]uint64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+	'w': 7 * 24 * 60 * 60,
+}
+
+// isTTL returns true if s is a TTL in seconds, or in BIND's units like 1h,
+// 1D, or 1h30m
+func isTTL(s string) bool {
+	if isNumber(s) {
+		return true
+	}
+	s = strings.ToLower(s)
+	total, digits := uint64(0), 0
+	for index := 0; index < len(s); index++ {
+		if s[index] >= '0' && s[index] <= '9' {
+			digits++
+			continue
+		}
+		unit, ok := ttlUnits[s[index]]
+		if !ok || digits == 0 {
+			return false
+		}
+		value, err := strconv.ParseUint(s[index-digits:index], 10, 32)
+		if err != nil {
+			return false
+		}
+		total += value * unit
+		digits = 0
+	}
+	return digits == 0 && total <= math.MaxUint32
+}
+
+// isClass returns true if s is a DNS class
+func isClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+// isType returns true if s looks like a record type, like A, AAAA, or
+// TYPE65534
+func isType(s string) bool {
+	for index, char := range s {
+		isLetter := (char >= 'A' && char <= 'Z') || (char >= 'a' && char <= 'z')
+		if !isLetter && (index == 0 || char < '0' || char > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseRecord splits the fields of a resource record like
+// api.local. 3600 IN A 10.0.0.1 into its name, type, and data. The TTL and
+// class are optional and may be in either order. It returns an error for a
+// record it can't parse, like one with a TTL in units it doesn't know, so the
+// record isn't mistaken for a type we ignore.
+func parseRecord(fields []string) (name, rtype string, rdata []string, err error) {
+	if len(fields) < 3 {
+		return "", "", nil, errors.New("expected a record like api IN A 10.0.0.1")
+	}
+	name = fields[0]
+	rest := fields[1:]
+	for len(rest) > 0 && (isTTL(rest[0]) || isClass(rest[0])) {
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return "", "", nil, errors.New("expected a record like api IN A 10.0.0.1")
+	}
+	if !isType(rest[0]) {
+		return "", "", nil, fmt.Errorf("%s is not a TTL, class, or record type", rest[0])
+	}
+	rtype = strings.ToUpper(rest[0])
+	// A TTL or class we don't understand would otherwise be read as the type,
+	// like INN in api INN A 10.0.0.1
+	if next := strings.ToUpper(rest[1]); len(rest) == 3 && (next == "A" || next == "AAAA") && rtype != "A" && rtype != "AAAA" {
+		if LooksLikeIPv4(rest[2]) || LooksLikeIPv6(rest[2]) {
+			return "", "", nil, fmt.Errorf("%s is not a TTL, class, or record type", rest[0])
+		}
+	}
+	return name, rtype, rest[1:], nil
+}
+
+// addressRecord creates a Hostname from an A or AAAA record, checking that the
+// IP is the right version for the record type.
+func addressRecord(domain, rtype, ip string) (*Hostname, error) {
+	hostname, err := importHostname(domain, ip)
+	if err != nil {
+		return nil, err
+	}
+	if hostname.IPv6 != (rtype == "AAAA") {
+		return nil, &MalformedLineError{Reason: fmt.Sprintf("%s is not a valid address for an %s record", ip, rtype)}
+	}
+	return hostname, nil
+}
+
+// ParseUnbound reads hosts entries from unbound configuration: local-data
+// lines like local-data: "api.local. IN A 10.0.0.1". Other record types,
+// local-data-ptr, and other options are ignored.
+func ParseUnbound(path string, data []byte) (Hostlist, []error) {
+	hostnames := Hostlist{}
+	errs := []error{}
+
+	scanLines(data, func(number int, text string) {
+		line := TrimWS(text)
+		if !strings.HasPrefix(line, "local-data:") {
+			return
+		}
+		value := TrimWS(strings.TrimPrefix(line, "local-data:"))
+		if len(value) < 2 || (value[0] != '"' && value[0] != '\'') || value[len(value)-1] != value[0] {
+			errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: line, Reason: "expected a quoted record"})
+			return
+		}
+
+		name, rtype, rdata, err := parseRecord(strings.Fields(value[1 : len(value)-1]))
+		if err != nil {
+			errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: line, Reason: err.Error()})
+			return
+		}
+		if rtype != "A" && rtype != "AAAA" {
+			return
+		}
+		hostname, err := addressRecord(name, rtype, rdata[0])
+		if err != nil {
+			errs = append(errs, locateError(err, path, number, line, 0))
+			return
+		}
+		hostnames = append(hostnames, hostname)
+	})
+	return hostnames, errs
+}
+
+// stripZoneComment removes a ; comment from a zone file line, unless the ; is
+// in a quoted string
+func stripZoneComment(line string) string {
+	quoted := false
+	for index := 0; index < len(line); index++ {
+		switch line[index] {
+		case '\\':
+			index++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:index]
+			}
+		}
+	}
+	return line
+}
+
+// ParseBind reads hosts entries from the A and AAAA records in an RFC 1035
+// zone file, like the ones BIND uses. Relative names are resolved using
+// $ORIGIN directives in the file, or origin if the file doesn't start with
+// one. Other record types are ignored.
+func ParseBind(path string, data []byte, origin string) (Hostlist, []error) {
+	hostnames := Hostlist{}
+	errs := []error{}
+	origin = strings.TrimSuffix(origin, ".")
+	owner := ""
+
+	// Records in parentheses may span several lines, so we collect them
+	// before parsing.
+	pending, pendingLine, depth := "", 0, 0
+
+	// resolve returns the fully qualified name for a name in the zone file
+	resolve := func(name string) (string, error) {
+		switch {
+		case strings.HasSuffix(name, "."):
+			return name, nil
+		case origin == "":
+			return "", fmt.Errorf("relative name %s with no origin; use $ORIGIN or -origin", name)
+		case name == "@":
+			return fqdn(origin), nil
+		}
+		return fqdn(name + "." + origin), nil
+	}
+
+	parse := func(number int, line string) {
+		// A line starting with a blank uses the previous owner name
+		inherit := line != "" && (line[0] == ' ' || line[0] == '\t')
+		fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(line))
+		if len(fields) == 0 {
+			return
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if len(fields) != 2 {
+				errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: TrimWS(line), Reason: "expected $ORIGIN <domain>"})
+				return
+			}
+			name, err := resolve(fields[1])
+			if err != nil {
+				errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: TrimWS(line), Reason: err.Error()})
+				return
+			}
+			origin = strings.TrimSuffix(name, ".")
+			return
+		case "$TTL":
+			return
+		case "$INCLUDE", "$GENERATE":
+			errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: TrimWS(line), Reason: fmt.Sprintf("%s is not supported", fields[0])})
+			return
+		}
+
+		if inherit {
+			if owner == "" {
+				errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: TrimWS(line), Reason: "record has no owner name"})
+				return
+			}
+			fields = append([]string{owner}, fields...)
+		}
+		name, rtype, rdata, err := parseRecord(fields)
+		if err != nil {
+			errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: TrimWS(line), Reason: err.Error()})
+			return
+		}
+		domain, err := resolve(name)
+		if err != nil {
+			errs = append(errs, &MalformedLineError{Path: path, Line: number, Text: TrimWS(line), Reason: err.Error()})
+			return
+		}
+		owner = domain
+		if rtype != "A" && rtype != "AAAA" {
+			return
+		}
+
+		hostname, err := addressRecord(domain, rtype, rdata[0])
+		if err != nil {
+			errs = append(errs, locateError(err, path, number, line, 0))
+			return
+		}
+		hostnames = append(hostnames, hostname)
+	}
+
+	scanLines(data, func(number int, text string) {
+		line := stripZoneComment(text)
+		if depth == 0 {
+			pending, pendingLine = "", number
+		}
+		pending += line + " "
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		if depth <= 0 {
+			depth = 0
+			parse(pendingLine, strings.TrimRight(pending, " "))
+		}
+	})
+	if depth > 0 {
+		errs = append(errs, &MalformedLineError{Path: path, Line: pendingLine, Text: TrimWS(pending), Reason: "missing )"})
+	}
+	return hostnames, errs
+}
@@ -0,0 +1,159 @@
+package hostess_test
+
+import (
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func formatImported(hostnames hostess.Hostlist) string {
+	out := ""
+	for _, hostname := range hostnames {
+		out += hostname.Format() + "\n"
+	}
+	return out
+}
+
+func TestParseDnsmasq(t *testing.T) {
+	const data = `# dev DNS
+domain-needed
+address=/api.local/www.local/10.0.0.1
+address=/blocked.local/
+host-record=db.local,10.0.0.2,fd00::2,300
+`
+	hostnames, errs := hostess.ParseDnsmasq("dnsmasq.conf", []byte(data))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	expected := `10.0.0.1 api.local
+10.0.0.1 www.local
+10.0.0.2 db.local
+fd00::2 db.local
+`
+	if output := formatImported(hostnames); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	_, errs = hostess.ParseDnsmasq("dnsmasq.conf", []byte("address=/#/10.0.0.1\nhost-record=db.local\n"))
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, found %v", errs)
+	}
+	if e, ok := errs[1].(*hostess.MalformedLineError); !ok || e.Line != 2 || e.Path != "dnsmasq.conf" {
+		t.Errorf("Expected a malformed line error on line 2, found %#v", errs[1])
+	}
+}
+
+func TestParseUnbound(t *testing.T) {
+	const data = `server:
+    local-zone: "local." static
+    local-data: "api.local. IN A 10.0.0.1"
+    local-data: 'api.local 1h IN AAAA fd00::1'
+    local-data: "api.local. IN TXT hello"
+    local-data-ptr: "10.0.0.1 api.local."
+`
+	hostnames, errs := hostess.ParseUnbound("unbound.conf", []byte(data))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	expected := `10.0.0.1 api.local
+fd00::1 api.local
+`
+	if output := formatImported(hostnames); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	_, errs = hostess.ParseUnbound("unbound.conf", []byte(`local-data: "api.local. IN A fd00::1"`))
+	if len(errs) != 1 {
+		t.Errorf("Expected an error for an IPv6 address in an A record, found %v", errs)
+	}
+}
+
+func TestParseBind(t *testing.T) {
+	const data = `$ORIGIN example.internal.
+$TTL 3600
+@	IN	SOA	ns.example.internal. hostmaster.example.internal. (
+		1	; serial
+		3600	; refresh
+		900	; retry
+		604800	; expire
+		3600 )	; minimum
+@	IN	NS	ns.example.internal.
+@	IN	A	10.0.1.5
+api	IN	A	10.0.0.1 ; the API
+	IN	AAAA	fd00::1
+www 300 CNAME api
+other.local.	IN	A	10.0.0.2
+ttl	1h	IN	A	10.0.0.50
+ttl	1D	AAAA	fd00::50
+ttl	IN	1h30m	A	10.0.0.51
+$ORIGIN sub.example.internal.
+db	A	10.0.0.3
+`
+	hostnames, errs := hostess.ParseBind("example.zone", []byte(data), "")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	expected := `10.0.1.5 example.internal
+10.0.0.1 api.example.internal
+fd00::1 api.example.internal
+10.0.0.2 other.local
+10.0.0.50 ttl.example.internal
+fd00::50 ttl.example.internal
+10.0.0.51 ttl.example.internal
+10.0.0.3 db.sub.example.internal
+`
+	if output := formatImported(hostnames); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	// Records we can't parse are reported, not mistaken for a type we ignore
+	const bad = `$ORIGIN example.internal.
+api	1x	IN	A	10.0.0.1
+api	INN	A	10.0.0.1
+api	1h5	A	10.0.0.1
+www	IN	A	10.0.0.2
+`
+	hostnames, errs = hostess.ParseBind("example.zone", []byte(bad), "")
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, found %v", errs)
+	}
+	for index, err := range errs {
+		if e, ok := err.(*hostess.MalformedLineError); !ok || e.Line != index+2 {
+			t.Errorf("Expected a malformed line error on line %d, found %#v", index+2, err)
+		}
+	}
+	if output := formatImported(hostnames); output != "10.0.0.2 www.example.internal\n" {
+		t.Errorf("Expected only www.example.internal, found:\n%s", output)
+	}
+
+	// Without $ORIGIN we need to be told the origin
+	if _, errs := hostess.ParseBind("example.zone", []byte("api IN A 10.0.0.1\n"), ""); len(errs) != 1 {
+		t.Errorf("Expected an error for a relative name without an origin, found %v", errs)
+	}
+	hostnames, errs = hostess.ParseBind("example.zone", []byte("api IN A 10.0.0.1\n"), "example.internal")
+	if len(errs) > 0 || len(hostnames) != 1 || hostnames[0].Domain != "api.example.internal" {
+		t.Errorf("Expected api.example.internal, found %v %v", hostnames, errs)
+	}
+}
+
+func TestBindRoundTrip(t *testing.T) {
+	hosts := exportHostlist()
+	zones, err := hosts.BindZones("local", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostnames, errs := hostess.ParseBind("local.zone", zones[0].Data, "")
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	// Disabled and zoned entries aren't exported, and neither is localhost
+	// since it isn't in the zone. ns.local is the stub for the NS record.
+	expected := `127.0.0.1 ns.local
+10.0.0.1 api.local
+fd00::1 api.local
+10.0.0.1 www.local
+`
+	if output := formatImported(hostnames); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
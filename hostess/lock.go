@@ -0,0 +1,64 @@
+package hostess
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrLockTimeout is returned when another process holds the lock on the hosts
+// file for longer than we are willing to wait.
+var ErrLockTimeout = errors.New("timed out waiting for another process to release the hosts file lock")
+
+// errLocked is returned by tryLock when the lock is held by someone else.
+var errLocked = errors.New("lock is held by another process")
+
+// lockRetryInterval is how long AcquireLock waits between attempts.
+const lockRetryInterval = 50 * time.Millisecond
+
+// Lock is an exclusive, advisory lock on a hosts file. It is held on a sidecar
+// file (see LockPath) rather than the hosts file itself, because Save may
+// replace the hosts file while the lock is held.
+//
+// The lock only works if everyone who changes the hosts file uses it, so if
+// you are writing a tool that changes the hosts file with this package you
+// should hold a Lock around loading, changing, and saving the Hostfile. The
+// hostess CLI does this for every command that changes the hosts file.
+type Lock struct {
+	file *os.File
+}
+
+// LockPath returns the path of the lock file for the hosts file at path.
+func LockPath(path string) string {
+	return path + ".lock"
+}
+
+// AcquireLock takes the lock for the hosts file at path, waiting up to timeout
+// for another process to release it. If timeout is zero we try exactly once.
+func AcquireLock(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := tryLock(LockPath(path))
+		if err == nil {
+			return &Lock{file: file}, nil
+		}
+		if err != errLocked {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLockTimeout
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Lock takes the lock for this Hostfile. See AcquireLock.
+func (h *Hostfile) Lock(timeout time.Duration) (*Lock, error) {
+	return AcquireLock(h.Path, timeout)
+}
+
+// Release gives up the lock. The lock file is left in place, since deleting it
+// would race with other processes trying to lock it.
+func (l *Lock) Release() error {
+	return l.file.Close()
+}
@@ -0,0 +1,46 @@
+package hostess_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostess-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hosts")
+
+	lock, err := hostess.AcquireLock(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hostess.AcquireLock(path, 100*time.Millisecond); err != hostess.ErrLockTimeout {
+		t.Fatalf("Expected %q, found %v", hostess.ErrLockTimeout, err)
+	}
+
+	// Once the lock is released it should be available to the next waiter
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lock.Release()
+	}()
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = path
+	second, err := hostfile.Lock(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package hostess
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLock opens the lock file and takes an exclusive flock on it without
+// blocking. The lock is released when the file is closed (or the process
+// exits).
+func tryLock(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
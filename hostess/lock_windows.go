@@ -0,0 +1,35 @@
+package hostess
+
+import (
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION, which syscall does not
+// export.
+const errorSharingViolation syscall.Errno = 32
+
+// tryLock opens the lock file without sharing, so nobody else can open it
+// until we close it. Windows releases the handle if the process exits.
+func tryLock(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := syscall.CreateFile(name,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, // No sharing
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if err == errorSharingViolation {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(handle), path), nil
+}
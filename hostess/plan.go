@@ -0,0 +1,157 @@
+package hostess
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStalePlan is returned by Plan.Apply when the hosts file changed after
+// the plan was made, so the plan may no longer do what it says.
+var ErrStalePlan = errors.New("hosts file changed after the plan was made; make a new plan")
+
+// copyHostlist copies each Hostname in h, so changing the copy doesn't change
+// h.
+func copyHostlist(h Hostlist) Hostlist {
+	copied := Hostlist{}
+	for _, hostname := range h {
+		c := *hostname
+		copied = append(copied, &c)
+	}
+	return copied
+}
+
+// Plan is the set of changes that applying a JSON file would make to a hosts
+// file. Checksum identifies the contents of the hosts file when the plan was
+// made, so the plan is only applied to the file it was made for.
+type Plan struct {
+	Path     string    `json:"path"`
+	Checksum string    `json:"checksum"`
+	Changes  []*Change `json:"changes"`
+}
+
+// Checksum returns the SHA-256 of the hosts file as we read it, in hex.
+func (h *Hostfile) Checksum() string {
+	sum := sha256.Sum256(h.data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Plan computes the changes that Hostlist.Apply (or Hostlist.Sync if prune
+// is true) would make with the JSON input, without changing the Hostfile.
+// Pruning returns ErrPruneWithoutBlock if the Hostfile has no managed block.
+//
+// Since hostess sweeps expired entries whenever it changes the hosts file, the
+// Plan also removes (or disables, see ShouldDisableExpired) the entries that
+// have expired, so Plan.Apply makes exactly the changes it lists.
+func (h *Hostfile) Plan(jsonbytes []byte, prune bool) (*Plan, error) {
+	if prune && h.Block == "" {
+		return nil, ErrPruneWithoutBlock
+	}
+	desired := copyHostlist(h.Hosts)
+	var err error
+	if prune {
+		_, err = desired.Sync(jsonbytes)
+	} else {
+		err = desired.Apply(jsonbytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ShouldDisableExpired() {
+		desired.DisableExpired(time.Now())
+	} else {
+		desired.RemoveExpired(time.Now())
+	}
+
+	return &Plan{
+		Path:     h.Path,
+		Checksum: h.Checksum(),
+		Changes:  Diff(h.Hosts, desired),
+	}, nil
+}
+
+// Apply makes the changes in the Plan to hostfile. If hostfile is not the same
+// as when the plan was made, Apply returns ErrStalePlan and makes no changes.
+func (p *Plan) Apply(hostfile *Hostfile) error {
+	if p.Checksum != hostfile.Checksum() {
+		return ErrStalePlan
+	}
+
+	for _, change := range p.Changes {
+		if change.Before != nil {
+			hostfile.Hosts.Remove(hostfile.Hosts.indexOfKey(hostnameKey(change.Before)))
+		}
+		if change.After != nil {
+			hostfile.Hosts.Add(change.After)
+		}
+	}
+	return nil
+}
+
+// Format outputs the Plan for people, with one change per line.
+func (p *Plan) Format() []byte {
+	if len(p.Changes) == 0 {
+		return []byte(fmt.Sprintf("No changes to %s\n", p.Path))
+	}
+	out := fmt.Sprintf("Changes to %s:\n", p.Path)
+	for _, change := range p.Changes {
+		out += "  " + change.String() + "\n"
+	}
+	return []byte(out)
+}
+
+// Dump exports the Plan as JSON
+func (p *Plan) Dump() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// planJSON is how a Plan looks in JSON. Hostnames in a plan may have come from
+// the hosts file, so we decode them ourselves to check their domains leniently
+// the same way Parse does.
+type planJSON struct {
+	Path     string `json:"path"`
+	Checksum string `json:"checksum"`
+	Changes  []struct {
+		Action string        `json:"action"`
+		Before *hostnameJSON `json:"before"`
+		After  *hostnameJSON `json:"after"`
+	} `json:"changes"`
+}
+
+// LoadPlan parses a Plan from JSON, e.g. the output of Plan.Dump
+func LoadPlan(jsonbytes []byte) (*Plan, error) {
+	decoded := planJSON{}
+	if err := json.Unmarshal(jsonbytes, &decoded); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Path: decoded.Path, Checksum: decoded.Checksum, Changes: []*Change{}}
+	for _, c := range decoded.Changes {
+		switch c.Action {
+		case ActionAdd, ActionUpdate, ActionEnable, ActionDisable, ActionRemove:
+		default:
+			return nil, fmt.Errorf("invalid action %q in plan", c.Action)
+		}
+		if c.Before == nil && c.After == nil {
+			return nil, fmt.Errorf("%s change in plan has no hostname", c.Action)
+		}
+
+		change := &Change{Action: c.Action}
+		var err error
+		if c.Before != nil {
+			if change.Before, err = c.Before.hostname(true); err != nil {
+				return nil, err
+			}
+		}
+		if c.After != nil {
+			if change.After, err = c.After.hostname(true); err != nil {
+				return nil, err
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
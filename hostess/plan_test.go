@@ -0,0 +1,144 @@
+package hostess_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+const planHostfile = `127.0.0.1 localhost
+127.0.1.1 devbox
+# BEGIN hostess
+10.0.0.1 api.local
+10.0.0.2 db.local
+# 10.0.0.3 web.local
+10.0.0.4 stale.local
+# END hostess
+`
+
+const planInput = `[
+  {"domain": "api.local", "ip": "10.0.0.5", "enabled": true},
+  {"domain": "db.local", "ip": "10.0.0.2", "enabled": false},
+  {"domain": "web.local", "ip": "10.0.0.3", "enabled": true},
+  {"domain": "new.local", "ip": "10.0.0.6", "enabled": true}
+]`
+
+func TestPlan(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+
+	hostfile := LoadTempHostfile(t, planHostfile)
+	defer os.Remove(hostfile.Path)
+
+	plan, err := hostfile.Plan([]byte(planInput), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Planning doesn't change anything
+	if output := string(hostfile.Format()); output != planHostfile {
+		t.Error(Diff(planHostfile, output))
+	}
+
+	expected := "Changes to " + hostfile.Path + `:
+  update  api.local -> 10.0.0.1 (On) => 10.0.0.5 (On)
+  disable db.local -> 10.0.0.2 (Off)
+  add     new.local -> 10.0.0.6 (On)
+  remove  stale.local -> 10.0.0.4 (On)
+  enable  web.local -> 10.0.0.3 (On)
+`
+	if output := string(plan.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	// The plan survives a round trip through JSON
+	planbytes, err := plan.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := hostess.LoadPlan(planbytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Apply(hostfile); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries outside the managed block are never pruned
+	expected = `127.0.0.1 localhost
+127.0.1.1 devbox
+# BEGIN hostess
+10.0.0.5 api.local
+# 10.0.0.2 db.local
+10.0.0.3 web.local
+10.0.0.6 new.local
+# END hostess
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestPlanPruneWithoutBlock(t *testing.T) {
+	hostfile := LoadTempHostfile(t, planHostfile)
+	defer os.Remove(hostfile.Path)
+
+	if _, err := hostfile.Plan([]byte(planInput), true); err != hostess.ErrPruneWithoutBlock {
+		t.Errorf("Expected ErrPruneWithoutBlock, found %v", err)
+	}
+}
+
+func TestPlanStale(t *testing.T) {
+	hostfile := LoadTempHostfile(t, planHostfile)
+	defer os.Remove(hostfile.Path)
+
+	plan, err := hostfile.Plan([]byte(planInput), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else changes the hosts file after we made the plan
+	if err := ioutil.WriteFile(hostfile.Path, []byte(planHostfile+"10.0.0.9 other.local\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed := hostess.NewHostfile()
+	changed.Path = hostfile.Path
+	if err := changed.Read(); err != nil {
+		t.Fatal(err)
+	}
+	changed.Parse()
+
+	if err := plan.Apply(changed); err != hostess.ErrStalePlan {
+		t.Errorf("Expected ErrStalePlan, found %v", err)
+	}
+}
+
+func TestPlanLegacyNames(t *testing.T) {
+	// my_host isn't a valid new name, but it's already in the hosts file so a
+	// saved plan that removes it can still be loaded
+	hostfile := LoadTempHostfile(t, "127.0.0.1 localhost\n10.0.0.1 my_host\n")
+	defer os.Remove(hostfile.Path)
+
+	plan, err := hostfile.Plan([]byte(`[{"domain": "my_host", "state": "absent"}]`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planbytes, err := plan.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := hostess.LoadPlan(planbytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Apply(hostfile); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "127.0.0.1 localhost\n"
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
@@ -0,0 +1,235 @@
+package hostess
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrProfileNotFound is returned when there are no hostnames in a profile
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ErrProfileExists is returned when creating a profile that already exists
+var ErrProfileExists = errors.New("profile already exists")
+
+// A profile is a named group of hostnames that can be enabled or disabled
+// together, e.g. staging or prod-debug. In the hosts file, a profile is a
+// section between these two lines:
+//
+//	# BEGIN profile staging
+//	10.1.2.3 api.example.com
+//	# END profile staging
+
+// ProfileBegin returns the comment that marks the start of a profile, e.g.
+// # BEGIN profile staging
+func ProfileBegin(name string) string {
+	return "# BEGIN profile " + name
+}
+
+// ProfileEnd returns the comment that marks the end of a profile, e.g.
+// # END profile staging
+func ProfileEnd(name string) string {
+	return "# END profile " + name
+}
+
+// parseProfileMarker returns the LineKind and profile name if text is a
+// profile marker. Otherwise it returns CommentLine.
+func parseProfileMarker(text string) (LineKind, string) {
+	fields := strings.Fields(text)
+	if len(fields) != 4 || fields[0] != "#" || fields[2] != "profile" {
+		return CommentLine, ""
+	}
+	if ValidateProfileName(fields[3]) != nil {
+		return CommentLine, ""
+	}
+	switch fields[1] {
+	case "BEGIN":
+		return ProfileBeginLine, fields[3]
+	case "END":
+		return ProfileEndLine, fields[3]
+	}
+	return CommentLine, ""
+}
+
+// ValidateProfileName checks that name can be used as a profile name: it
+// may only contain letters, digits, dots, hyphens, and underscores.
+func ValidateProfileName(name string) error {
+	if name == "" {
+		return errors.New("profile name is blank")
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return fmt.Errorf("profile name %q contains invalid character %q", name, c)
+		}
+	}
+	return nil
+}
+
+// FilterByProfile returns the Hostnames in the named profile. Pass a blank
+// name to get the Hostnames that are not in a profile.
+func (h *Hostlist) FilterByProfile(name string) Hostlist {
+	hostnames := Hostlist{}
+	for _, hostname := range *h {
+		if hostname.Profile == name {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
+
+// disableConflicts disables the enabled Hostnames in other profiles (or not in
+// a profile) with the same domain, IP version, and zone as hostname. Since
+// only one entry for a domain can take effect, hostname wins. disableConflicts
+// returns a *ConflictError for the last Hostname it disabled, or nil.
+func (h *Hostlist) disableConflicts(hostname *Hostname) error {
+	var err error
+	for _, found := range *h {
+		if !found.Enabled || found.Profile == hostname.Profile {
+			continue
+		}
+		if versionKey(found) == versionKey(hostname) {
+			found.Enabled = false
+			err = &ConflictError{Previous: found, Hostname: hostname}
+		}
+	}
+	return err
+}
+
+// EnableProfile enables every Hostname in the named profile. Since only one
+// entry for a domain can take effect, any enabled Hostnames in other profiles
+// (or not in a profile) with the same domain and IP version are disabled.
+func (h *Hostlist) EnableProfile(name string) error {
+	profile := h.FilterByProfile(name)
+	if name == "" || len(profile) == 0 {
+		return ErrProfileNotFound
+	}
+
+	for _, hostname := range profile {
+		hostname.Enabled = true
+		h.disableConflicts(hostname)
+	}
+	return nil
+}
+
+// DisableProfile disables every Hostname in the named profile.
+func (h *Hostlist) DisableProfile(name string) error {
+	profile := h.FilterByProfile(name)
+	if name == "" || len(profile) == 0 {
+		return ErrProfileNotFound
+	}
+	for _, hostname := range profile {
+		hostname.Enabled = false
+	}
+	return nil
+}
+
+// RemoveProfile removes every Hostname in the named profile. Returns the
+// number of entries removed.
+func (h *Hostlist) RemoveProfile(name string) int {
+	kept := Hostlist{}
+	for _, hostname := range *h {
+		if hostname.Profile != name {
+			kept = append(kept, hostname)
+		}
+	}
+	removed := len(*h) - len(kept)
+	*h = kept
+	return removed
+}
+
+// ListProfiles returns the names of the profiles in the hosts file, including
+// empty profiles that were created with CreateProfile and profiles that
+// Hostnames were added to.
+func (h *Hostfile) ListProfiles() []string {
+	seen := map[string]bool{}
+	var profiles []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			profiles = append(profiles, name)
+		}
+	}
+	for _, name := range h.Profiles {
+		add(name)
+	}
+	for _, hostname := range h.Hosts {
+		add(hostname.Profile)
+	}
+	return profiles
+}
+
+// HasProfile returns true if the named profile exists. See ListProfiles.
+func (h *Hostfile) HasProfile(name string) bool {
+	for _, profile := range h.ListProfiles() {
+		if profile == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateProfile adds an empty profile to the hosts file.
+func (h *Hostfile) CreateProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if h.HasProfile(name) {
+		return ErrProfileExists
+	}
+	h.Profiles = append(h.Profiles, name)
+	return nil
+}
+
+// RemoveProfile removes the named profile and all of the Hostnames in it from
+// the hosts file.
+func (h *Hostfile) RemoveProfile(name string) error {
+	if !h.HasProfile(name) {
+		return ErrProfileNotFound
+	}
+	h.Hosts.RemoveProfile(name)
+	profiles := []string{}
+	for _, profile := range h.Profiles {
+		if profile != name {
+			profiles = append(profiles, profile)
+		}
+	}
+	h.Profiles = profiles
+	return nil
+}
+
+// declaredProfiles returns the names of the profiles that have sections in
+// lines, in the order they appear.
+func declaredProfiles(lines []*Line) []string {
+	var profiles []string
+	for _, line := range lines {
+		if line.Kind == ProfileBeginLine {
+			profiles = append(profiles, line.Profile)
+		}
+	}
+	return profiles
+}
+
+// formatProfile formats a profile section containing hosts
+func formatProfile(name string, hosts Hostlist) []byte {
+	out := bytes.Buffer{}
+	out.WriteString(ProfileBegin(name) + "\n")
+	out.Write(hosts.Format())
+	out.WriteString(ProfileEnd(name) + "\n")
+	return out.Bytes()
+}
+
+// formatHosts formats hosts in the canonical layout: Hostnames that are not
+// in a profile first, followed by a section for each profile.
+func formatHosts(hosts Hostlist, profiles []string) []byte {
+	out := bytes.Buffer{}
+	unprofiled := hosts.FilterByProfile("")
+	out.Write(unprofiled.Format())
+	for _, name := range profiles {
+		out.Write(formatProfile(name, hosts.FilterByProfile(name)))
+	}
+	return out.Bytes()
+}
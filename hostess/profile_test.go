@@ -0,0 +1,276 @@
+package hostess_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+const profileHostfile = `127.0.0.1 localhost
+# BEGIN profile staging
+10.1.2.3 api.example.com
+10.1.2.4 www.example.com
+# END profile staging
+# BEGIN profile prod-debug
+# 52.1.2.3 api.example.com
+# END profile prod-debug
+`
+
+func TestParseProfiles(t *testing.T) {
+	hostfile := LoadTempHostfile(t, profileHostfile)
+	defer os.Remove(hostfile.Path)
+
+	profiles := hostfile.ListProfiles()
+	if len(profiles) != 2 || profiles[0] != "staging" || profiles[1] != "prod-debug" {
+		t.Fatalf("Expected staging and prod-debug, found %v", profiles)
+	}
+
+	// The same domain may appear in more than one profile
+	if len(hostfile.Hosts) != 4 {
+		t.Fatalf("Expected 4 hostnames, found %d", len(hostfile.Hosts))
+	}
+	staging := hostfile.Hosts.FilterByProfile("staging")
+	if len(staging) != 2 || !staging.ContainsDomain("www.example.com") {
+		t.Errorf("Expected 2 hostnames in staging, found %v", staging)
+	}
+
+	// Nothing changed so we should get back exactly what we read
+	if output := string(hostfile.Format()); output != profileHostfile {
+		t.Error(Diff(profileHostfile, output))
+	}
+}
+
+func TestEnableProfile(t *testing.T) {
+	hostfile := LoadTempHostfile(t, profileHostfile)
+	defer os.Remove(hostfile.Path)
+
+	if err := hostfile.Hosts.EnableProfile("prod-debug"); err != nil {
+		t.Fatal(err)
+	}
+	// api.example.com in staging is disabled so the prod-debug entry takes
+	// effect, but www.example.com is left alone.
+	expected := `127.0.0.1 localhost
+# BEGIN profile staging
+# 10.1.2.3 api.example.com
+10.1.2.4 www.example.com
+# END profile staging
+# BEGIN profile prod-debug
+52.1.2.3 api.example.com
+# END profile prod-debug
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	if err := hostfile.Hosts.DisableProfile("prod-debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hostfile.Hosts.EnableProfile("missing"); err != hostess.ErrProfileNotFound {
+		t.Errorf("Expected ErrProfileNotFound, found %v", err)
+	}
+}
+
+func TestProfileConflicts(t *testing.T) {
+	hostfile := LoadTempHostfile(t, "127.0.0.1 localhost\n10.0.0.1 api.example.com\n")
+	defer os.Remove(hostfile.Path)
+
+	// Adding an enabled entry in a profile disables the entry that is not in a
+	// profile, so resolvers see the profile's IP
+	hostname := hostess.MustHostname("api.example.com", "10.1.2.3", true)
+	hostname.Profile = "staging"
+	if _, ok := hostfile.Hosts.Add(hostname).(*hostess.ConflictError); !ok {
+		t.Error("Expected a ConflictError adding api.example.com to staging")
+	}
+	hostname = hostess.MustHostname("api.example.com", "52.1.2.3", false)
+	hostname.Profile = "prod"
+	if err := hostfile.Hosts.Add(hostname); err != nil {
+		t.Errorf("Expected no error adding a disabled entry, found %v", err)
+	}
+
+	expected := `127.0.0.1 localhost
+# 10.0.0.1 api.example.com
+# BEGIN profile staging
+10.1.2.3 api.example.com
+# END profile staging
+# BEGIN profile prod
+# 52.1.2.3 api.example.com
+# END profile prod
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	// Enabling a profile disables the entry in any other profile
+	if err := hostfile.Hosts.EnableProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	enabled := 0
+	for _, hostname := range hostfile.Hosts {
+		if hostname.Enabled && hostname.Domain == "api.example.com" {
+			enabled++
+			if hostname.Profile != "prod" {
+				t.Errorf("Expected prod to be enabled, found %s", hostname.Profile)
+			}
+		}
+	}
+	if enabled != 1 {
+		t.Errorf("Expected one enabled entry for api.example.com, found %d", enabled)
+	}
+}
+
+func TestEnableProfileConflicts(t *testing.T) {
+	hostfile := LoadTempHostfile(t, "127.0.0.1 localhost\n# 10.0.0.1 api.example.com\n")
+	defer os.Remove(hostfile.Path)
+	hostname := hostess.MustHostname("api.example.com", "10.1.2.3", true)
+	hostname.Profile = "staging"
+	hostfile.Hosts.Add(hostname)
+
+	// staging is already enabled, so it stays that way
+	if err := hostfile.Hosts.Enable("api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if unprofiled := hostfile.Hosts.FilterByProfile(""); unprofiled[1].Enabled {
+		t.Error("Expected the entry outside staging to stay disabled")
+	}
+
+	// Otherwise we enable the entry that is not in a profile
+	hostfile.Hosts.DisableProfile("staging")
+	if err := hostfile.Hosts.Enable("api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if unprofiled := hostfile.Hosts.FilterByProfile(""); !unprofiled[1].Enabled {
+		t.Error("Expected the entry outside staging to be enabled")
+	}
+	if staging := hostfile.Hosts.FilterByProfile("staging"); staging[0].Enabled {
+		t.Error("Expected the entry in staging to be disabled")
+	}
+}
+
+func TestParseProfileConflicts(t *testing.T) {
+	tempfile, err := ioutil.TempFile("", "hostess-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempfile.Name())
+	data := `10.0.0.1 api.example.com
+# BEGIN profile staging
+10.1.2.3 api.example.com
+# END profile staging
+`
+	if _, err := tempfile.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	tempfile.Close()
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = tempfile.Name()
+	if err := hostfile.Read(); err != nil {
+		t.Fatal(err)
+	}
+	errs := hostfile.Parse()
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, found %v", errs)
+	}
+	if e, ok := errs[0].(*hostess.ConflictError); !ok || e.Line != 3 || e.FirstLine != 1 {
+		t.Errorf("Expected a conflict on line 3 with line 1, found %#v", errs[0])
+	}
+	if hostfile.Hosts.FilterByProfile("")[0].Enabled {
+		t.Error("Expected the entry in staging to win")
+	}
+}
+
+func TestCreateAndRemoveProfile(t *testing.T) {
+	hostfile := LoadTempHostfile(t, profileHostfile)
+	defer os.Remove(hostfile.Path)
+
+	if err := hostfile.CreateProfile("local"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hostfile.CreateProfile("local"); err != hostess.ErrProfileExists {
+		t.Errorf("Expected ErrProfileExists, found %v", err)
+	}
+	if err := hostfile.CreateProfile("not valid"); err == nil {
+		t.Error("Expected an error for an invalid profile name")
+	}
+
+	hostname := hostess.MustHostname("api.example.com", "127.0.0.1", false)
+	hostname.Profile = "local"
+	if err := hostfile.Hosts.Add(hostname); err != nil {
+		t.Fatal(err)
+	}
+	hostname = hostess.MustHostname("db.example.com", "10.1.2.5", true)
+	hostname.Profile = "staging"
+	if err := hostfile.Hosts.Add(hostname); err != nil {
+		t.Fatal(err)
+	}
+	if err := hostfile.RemoveProfile("prod-debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `127.0.0.1 localhost
+# BEGIN profile staging
+10.1.2.3 api.example.com
+10.1.2.4 www.example.com
+10.1.2.5 db.example.com
+# END profile staging
+# BEGIN profile local
+# 127.0.0.1 api.example.com
+# END profile local
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	// Without the layout we get each profile in its own section
+	hostfile.DiscardLayout()
+	expected = `127.0.0.1 localhost
+# BEGIN profile staging
+10.1.2.3 api.example.com
+10.1.2.4 www.example.com
+10.1.2.5 db.example.com
+# END profile staging
+# BEGIN profile local
+# 127.0.0.1 api.example.com
+# END profile local
+`
+	if output := string(hostfile.Format()); output != expected {
+		t.Error(Diff(expected, output))
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	cases := map[string]int{
+		"# BEGIN profile a\n10.0.0.1 a.local\n":                   1,
+		"10.0.0.1 a.local\n# END profile a\n":                     2,
+		"# BEGIN profile a\n# BEGIN profile b\n# END profile b\n": 2,
+		"# BEGIN profile a\n10.0.0.1 a.local\n# END profile b\n":  3,
+	}
+
+	for data, line := range cases {
+		tempfile, err := ioutil.TempFile("", "hostess-test-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(tempfile.Name())
+		if _, err := tempfile.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		tempfile.Close()
+
+		hostfile := hostess.NewHostfile()
+		hostfile.Path = tempfile.Name()
+		if err := hostfile.Read(); err != nil {
+			t.Fatal(err)
+		}
+		errs := hostfile.Parse()
+		if len(errs) == 0 {
+			t.Errorf("Expected an error for %q", data)
+			continue
+		}
+		if e, ok := errs[0].(*hostess.MalformedLineError); !ok || e.Line != line {
+			t.Errorf("Expected malformed line %d for %q, found %#v", line, data, errs[0])
+		}
+	}
+}
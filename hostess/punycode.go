@@ -0,0 +1,187 @@
+package hostess
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements the Punycode encoding from RFC 3492, which is used to
+// represent Unicode domain names as ASCII (e.g. bücher -> xn--bcher-kva).
+
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+// punycodeAdapt is the bias adaptation function from RFC 3492 section 6.1
+func punycodeAdapt(delta, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+// punycodeThreshold clamps k - bias to the range [tmin, tmax]
+func punycodeThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punycodeTMin
+	case k >= bias+punycodeTMax:
+		return punycodeTMax
+	}
+	return k - bias
+}
+
+func punycodeEncodeDigit(digit int) byte {
+	if digit < 26 {
+		return byte('a' + digit)
+	}
+	return byte('0' + digit - 26)
+}
+
+func punycodeDecodeDigit(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	}
+	return 0, false
+}
+
+// punycodeEncode converts a Unicode label to Punycode, without the xn--
+// prefix.
+func punycodeEncode(input string) (string, error) {
+	if !utf8.ValidString(input) {
+		return "", errPunycode
+	}
+	runes := []rune(input)
+
+	out := []byte{}
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+
+	n := punycodeInitialN
+	delta := 0
+	bias := punycodeInitialBias
+	for handled < len(runes) {
+		// Find the smallest code point we haven't handled yet
+		m := math.MaxInt32
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := punycodeThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out = append(out, punycodeEncodeDigit(t+(q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			out = append(out, punycodeEncodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+
+	return string(out), nil
+}
+
+// punycodeDecode converts a Punycode label, without the xn-- prefix, back to
+// Unicode.
+func punycodeDecode(input string) (string, error) {
+	output := []rune{}
+	pos := 0
+	if index := strings.LastIndex(input, "-"); index > -1 {
+		for i := 0; i < index; i++ {
+			if input[i] >= utf8.RuneSelf {
+				return "", errPunycode
+			}
+			output = append(output, rune(input[i]))
+		}
+		pos = index + 1
+	}
+
+	n := punycodeInitialN
+	i := 0
+	bias := punycodeInitialBias
+	for pos < len(input) {
+		oldi := i
+		w := 1
+		for k := punycodeBase; ; k += punycodeBase {
+			if pos >= len(input) {
+				return "", errPunycode
+			}
+			digit, ok := punycodeDecodeDigit(input[pos])
+			pos++
+			if !ok {
+				return "", errPunycode
+			}
+			i += digit * w
+			// Domain labels are short, so anything this big is garbage
+			if i > utf8.MaxRune*64 || w > utf8.MaxRune*64 {
+				return "", errPunycode
+			}
+			t := punycodeThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punycodeBase - t
+		}
+		bias = punycodeAdapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > utf8.MaxRune {
+			return "", errPunycode
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,145 @@
+package hostess
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ExternalChangeError is returned by Rebase and Save when another program
+// changed the hosts file after we read it, and its changes conflict with the
+// changes we are trying to save.
+type ExternalChangeError struct {
+	Path string
+	// Conflicts lists the domains that were changed both by us and by the
+	// other program.
+	Conflicts []string
+	// Diff shows what the other program changed, in unified diff format.
+	Diff []byte
+}
+
+func (e *ExternalChangeError) Error() string {
+	return fmt.Sprintf("%s was changed by another program after hostess read it, and those changes conflict with ours for %s. Please try again.\n%s",
+		e.Path, strings.Join(e.Conflicts, ", "), e.Diff)
+}
+
+// sameHostname returns true if a and b are both nil, or have the same domain,
+// IP, enabled state, and inline comment.
+func sameHostname(a, b *Hostname) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b) && a.Enabled == b.Enabled && a.FormatComment() == b.FormatComment()
+}
+
+// Rebase checks whether the hosts file on disk still matches what we read. If
+// another program changed it in the meantime, Rebase replays our changes on
+// top of theirs: for each hostname, whichever side changed it wins. If both
+// sides changed the same hostname in different ways, Rebase returns an
+// *ExternalChangeError and leaves the Hostfile alone.
+//
+// After a successful Rebase, the Hostfile contains the other program's changes
+// as well as our own, so Format and Save will keep both.
+func (h *Hostfile) Rebase() error {
+	if !h.read {
+		return nil
+	}
+
+	disk, err := ioutil.ReadFile(h.Path)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(disk, h.data) {
+		return nil
+	}
+
+	base := Hostlist{}
+	baseLines, _, _ := h.parse(h.data, &base)
+	theirs := Hostlist{}
+	theirLines, theirBlock, errs := h.parse(disk, &theirs)
+	if theirBlock == nil {
+		// They broke the managed block markers so we can't tell which part
+		// of the file is ours anymore.
+		return errs[0]
+	}
+
+	index := func(hosts Hostlist) map[string]*Hostname {
+		indexed := map[string]*Hostname{}
+		for _, hostname := range hosts {
+			indexed[hostnameKey(hostname)] = hostname
+		}
+		return indexed
+	}
+	baseIndex := index(base)
+	mineIndex := index(h.Hosts)
+	theirIndex := index(theirs)
+
+	// Walk their hostnames first and then ours so the merged list keeps their
+	// order, with anything we added at the end.
+	var keys []string
+	domains := map[string]string{}
+	for _, hosts := range []Hostlist{theirs, h.Hosts, base} {
+		for _, hostname := range hosts {
+			key := hostnameKey(hostname)
+			if _, seen := domains[key]; !seen {
+				domains[key] = hostname.Domain
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	merged := Hostlist{}
+	var conflicts []string
+	for _, key := range keys {
+		original, mine, their := baseIndex[key], mineIndex[key], theirIndex[key]
+
+		var result *Hostname
+		switch {
+		case sameHostname(mine, original):
+			result = their
+		case sameHostname(their, original), sameHostname(mine, their):
+			result = mine
+		default:
+			conflicts = append(conflicts, domains[key])
+			continue
+		}
+
+		if result != nil {
+			merged = append(merged, result)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &ExternalChangeError{
+			Path:      h.Path,
+			Conflicts: conflicts,
+			Diff:      UnifiedDiff(h.Path+" (when hostess read it)", h.Path+" (now)", h.data, disk),
+		}
+	}
+
+	// Keep any profiles they created along with ours
+	baseProfiles := map[string]bool{}
+	for _, profile := range declaredProfiles(baseLines) {
+		baseProfiles[profile] = true
+	}
+	for _, profile := range declaredProfiles(theirLines) {
+		if !baseProfiles[profile] && !h.HasProfile(profile) {
+			h.Profiles = append(h.Profiles, profile)
+		}
+	}
+
+	h.data = disk
+	h.block = theirBlock
+	h.Hosts = merged
+	// If the layout was discarded we'll keep it that way, and if it was
+	// canonicalized (e.g. for fmt) we'll canonicalize their lines too.
+	if h.Lines != nil {
+		h.Lines = theirLines
+		if h.canonical {
+			h.Lines = canonicalLines(h.Lines, h.Hosts)
+		}
+	}
+
+	return nil
+}
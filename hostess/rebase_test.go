@@ -0,0 +1,109 @@
+package hostess_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+const rebaseHostfile = `127.0.0.1 localhost
+# Development
+10.0.0.5 devbox
+10.0.0.6 testbox
+`
+
+// LoadTempHostfile writes data to a temp file and loads it. Remove the file
+// when you're done with it.
+func LoadTempHostfile(t *testing.T, data string) *hostess.Hostfile {
+	t.Helper()
+	tempfile, err := ioutil.TempFile("", "hostess-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tempfile.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	tempfile.Close()
+
+	hostfile := hostess.NewHostfile()
+	hostfile.Path = tempfile.Name()
+	if err := hostfile.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if errs := hostfile.Parse(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	return hostfile
+}
+
+func TestSaveMergesExternalChanges(t *testing.T) {
+	hostfile := LoadTempHostfile(t, rebaseHostfile)
+	defer os.Remove(hostfile.Path)
+
+	hostfile.Hosts.Add(hostess.MustHostname("devbox", "10.0.0.7", true))
+
+	// Someone else removes testbox and adds a comment and a new entry
+	external := `127.0.0.1 localhost
+# Development (ask ops before changing)
+10.0.0.5 devbox
+10.0.0.9 buildbox
+`
+	if err := ioutil.WriteFile(hostfile.Path, []byte(external), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hostfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `127.0.0.1 localhost
+# Development (ask ops before changing)
+10.0.0.7 devbox
+10.0.0.9 buildbox
+`
+	data, err := ioutil.ReadFile(hostfile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Error(Diff(expected, string(data)))
+	}
+}
+
+func TestSaveRejectsConflictingExternalChanges(t *testing.T) {
+	hostfile := LoadTempHostfile(t, rebaseHostfile)
+	defer os.Remove(hostfile.Path)
+
+	hostfile.Hosts.Add(hostess.MustHostname("devbox", "10.0.0.7", true))
+
+	external := `127.0.0.1 localhost
+# Development
+10.0.0.8 devbox
+10.0.0.6 testbox
+`
+	if err := ioutil.WriteFile(hostfile.Path, []byte(external), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := hostfile.Save()
+	changeErr, ok := err.(*hostess.ExternalChangeError)
+	if !ok {
+		t.Fatalf("Expected *ExternalChangeError, found %v", err)
+	}
+	if len(changeErr.Conflicts) != 1 || changeErr.Conflicts[0] != "devbox" {
+		t.Errorf("Expected conflict on devbox, found %v", changeErr.Conflicts)
+	}
+	if len(changeErr.Diff) == 0 {
+		t.Error("Expected a diff of the external changes")
+	}
+
+	data, err := ioutil.ReadFile(hostfile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != external {
+		t.Errorf("Expected hosts file to be left alone: %s", Diff(external, string(data)))
+	}
+}
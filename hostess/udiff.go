@@ -0,0 +1,263 @@
+package hostess
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a
+// unified diff.
+const diffContext = 3
+
+// maxDiffDistance caps the work we do to find a minimal diff. If the inputs
+// differ by more lines than this we show the entire file as replaced.
+const maxDiffDistance = 1000
+
+// diffOp is a single line in an edit script: ' ' (unchanged), '-' (only in
+// the old text), or '+' (only in the new text).
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// splitLines breaks text into lines, keeping the trailing newline on each one
+// so that a missing newline at the end of the file shows up as a change.
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(text), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes an edit script that turns a into b using Myers'
+// algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v for diagonals -d through d after step d.
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxDiffDistance {
+			return replaceAll(a, b)
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrack(a, b, trace)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	// Unreachable; we always find a path by d == n+m.
+	return replaceAll(a, b)
+}
+
+// backtrack walks the trace from diffLines backwards to build the edit script.
+func backtrack(a, b []string, trace [][]int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		previous := trace[d-1]
+		get := func(k int) int {
+			return previous[k+d-1]
+		}
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[prevY]})
+		} else {
+			ops = append(ops, diffOp{'-', a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	// We built the script back to front
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAll is an edit script that removes all of a and adds all of b.
+func replaceAll(a, b []string) []diffOp {
+	var ops []diffOp
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// UnifiedDiff compares a and b line by line and returns the differences in
+// unified diff format, using nameA and nameB as the file names in the header.
+// If a and b are the same, UnifiedDiff returns nil.
+func UnifiedDiff(nameA, nameB string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	out := bytes.Buffer{}
+	out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", nameA, nameB))
+
+	// lineA and lineB are the 0-based line numbers of ops[index] in a and b
+	lineA, lineB := 0, 0
+	index := 0
+	for index < len(ops) {
+		// Skip ahead to the next change
+		if ops[index].kind == ' ' {
+			lineA++
+			lineB++
+			index++
+			continue
+		}
+
+		// Back up to include leading context
+		start := index
+		for start > 0 && index-start < diffContext && ops[start-1].kind == ' ' {
+			start--
+		}
+		startA := lineA - (index - start)
+		startB := lineB - (index - start)
+
+		// Extend the hunk until we find more than 2x context unchanged lines
+		// in a row, or run out of lines.
+		end := index
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := 0
+			for end+run < len(ops) && ops[end+run].kind == ' ' {
+				run++
+			}
+			if end+run == len(ops) || run > 2*diffContext {
+				if run > diffContext {
+					run = diffContext
+				}
+				end += run
+				break
+			}
+			end += run
+		}
+
+		countA, countB := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+
+		out.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB)))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		lineA = startA + countA
+		lineB = startB + countB
+		index = end
+	}
+
+	return out.Bytes()
+}
+
+// hunkRange formats the start and length of one side of a hunk. start is
+// 0-based but hunk headers are 1-based, except that an empty range refers to
+// the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// ANSI escape codes used by ColorDiff
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// ColorDiff adds terminal colors to the output of UnifiedDiff: red for removed
+// lines, green for added lines, and cyan for hunk headers.
+func ColorDiff(diff []byte) []byte {
+	out := bytes.Buffer{}
+	for _, line := range splitLines(diff) {
+		text := strings.TrimSuffix(line, "\n")
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			color = colorBold
+		case strings.HasPrefix(text, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(text, "-"):
+			color = colorRed
+		case strings.HasPrefix(text, "+"):
+			color = colorGreen
+		}
+		if color == "" {
+			out.WriteString(line)
+			continue
+		}
+		out.WriteString(color + text + colorReset + "\n")
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,78 @@
+package hostess_test
+
+import (
+	"testing"
+
+	"github.com/cbednarski/hostess/hostess"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := `127.0.0.1 localhost
+127.0.1.1 ubuntu
+10.0.0.1 one
+10.0.0.2 two
+10.0.0.3 three
+10.0.0.4 four
+10.0.0.5 five
+10.0.0.6 six
+10.0.0.7 seven
+10.0.0.8 eight
+10.0.0.9 nine
+`
+	b := `127.0.0.1 localhost
+127.0.1.1 ubuntu
+10.0.0.1 one
+10.0.0.22 two
+10.0.0.3 three
+10.0.0.4 four
+10.0.0.5 five
+10.0.0.6 six
+10.0.0.7 seven
+10.0.0.8 eight
+10.0.0.9 nine
+10.0.0.10 ten`
+
+	expected := `--- a
++++ b
+@@ -1,7 +1,7 @@
+ 127.0.0.1 localhost
+ 127.0.1.1 ubuntu
+ 10.0.0.1 one
+-10.0.0.2 two
++10.0.0.22 two
+ 10.0.0.3 three
+ 10.0.0.4 four
+ 10.0.0.5 five
+@@ -9,3 +9,4 @@
+ 10.0.0.7 seven
+ 10.0.0.8 eight
+ 10.0.0.9 nine
++10.0.0.10 ten
+\ No newline at end of file
+`
+
+	output := string(hostess.UnifiedDiff("a", "b", []byte(a), []byte(b)))
+	if output != expected {
+		t.Error(Diff(expected, output))
+	}
+
+	if diff := hostess.UnifiedDiff("a", "a", []byte(a), []byte(a)); diff != nil {
+		t.Errorf("Expected no diff for identical input, found %s", diff)
+	}
+}
+
+func TestColorDiff(t *testing.T) {
+	diff := hostess.UnifiedDiff("a", "b", []byte("one\ntwo\n"), []byte("one\nthree\n"))
+
+	expected := "\x1b[1m--- a\x1b[0m\n" +
+		"\x1b[1m+++ b\x1b[0m\n" +
+		"\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n" +
+		" one\n" +
+		"\x1b[31m-two\x1b[0m\n" +
+		"\x1b[32m+three\x1b[0m\n"
+
+	output := string(hostess.ColorDiff(diff))
+	if output != expected {
+		t.Errorf("Expected %q, found %q", expected, output)
+	}
+}
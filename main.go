@@ -7,6 +7,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/cbednarski/hostess/hostess"
 )
@@ -15,29 +17,106 @@ const help = `An idempotent tool for managing %s
 
 Commands
 
-    fmt                  Reformat the hosts file
+    fmt                  Reformat and sort the entries in the hosts file,
+                         keeping comments and blank lines
+    fmt -check [file...] Exit 1 if the hosts file (or each file) is not
+                         formatted or has duplicates or conflicts
 
-    add <hostname> <ip>  Add or overwrite a hosts entry
+    add <hostname> <ip>  Add or overwrite a hosts entry. Use -profile <name>
+                         to add it to a profile, -ttl or -until to make it
+                         temporary, and -comment or -meta to note why it's
+                         there
     rm <hostname>        Remote a hosts entry
     on <hostname>        Enable a hosts entry
     off <hostname>       Disable a hosts entry
 
+    gc                   Remove expired hosts entries. Expired entries are
+                         also removed by every other command that changes
+                         the hosts file
+
     ls                   List hosts entries
     has                  Exit 0 if entry present in hosts file, 1 if not
 
-    dump                 Export hosts entries as JSON
-    apply                Import hosts entries from JSON
-
-    All commands that change the hosts file will implicitly reformat it.
+    dump                 Export hosts entries as JSON. Use -format to export
+                         enabled entries for another program instead
+    apply <file>         Import hosts entries from JSON. Entries with
+                         "state": "absent" are removed. Use -prune to also
+                         remove entries that are not in the file, and
+                         -format csv or tsv to import a spreadsheet
+    import <file>        Add hosts entries from another program's config.
+                         Use -format dnsmasq, unbound, or bind
+    plan <file>          Show what apply would change, without changing
+                         anything. Use -json to save the plan for apply -plan
+    apply -plan <plan>   Make the changes in a plan from plan -json. Fails if
+                         the hosts file changed since the plan was made
+    diff <fileA> <fileB> Show the entries added, updated, enabled, disabled,
+                         or removed between two hosts files or JSON dumps.
+                         Exits 1 if there are any differences
+
+    profile create <name>   Create an empty profile
+    profile enable <name>   Enable all entries in a profile (and disable the
+                            same hostnames outside the profile)
+    profile disable <name>  Disable all entries in a profile
+    profile ls              List profiles
+    profile rm <name>       Remove a profile and all of its entries
+
+    backups              List backups of the hosts file
+    restore [id]         Restore the newest backup, or the backup with id
+
+    Other commands that change the hosts file only rewrite the lines they
+    touch, and preserve comments and blank lines. A backup is saved before
+    each change.
 
 Flags
 
-    -n will preview changes but not rewrite your hosts file
+    -n will preview changes as a diff but not rewrite your hosts file
+    -full with -n shows the entire resulting hosts file instead of a diff
+    -color with -n or fmt -check colorizes the diff for terminals
+    -lock-timeout is how long to wait for another hostess process to finish
+      changing the hosts file, e.g. 30s (default 10s)
+    -profile with add puts the entry in a profile
+    -ttl with add makes the entry expire after a duration, e.g. 2h or 30m
+    -until with add makes the entry expire at a time, e.g. 2026-11-01T00:00Z
+    -comment with add saves a comment with the entry, e.g. "bypass CDN"
+    -meta with add saves key=value metadata with the entry, e.g. owner=alice.
+      Repeat it to add more than one key
+    -prune with apply or plan removes entries in the managed block that are
+      not in the JSON, except localhost. Requires HOSTESS_BLOCK
+    -json with plan or diff outputs the changes as JSON
+    -format with dump picks the output format: json (default), dnsmasq
+      (host-record), dnsmasq-address (address=, also matches subdomains),
+      unbound, coredns (hosts plugin block), bind (zone files), kubernetes
+      (Pod hostAliases), docker (--add-host flags), compose (extra_hosts),
+      curl (--resolve flags), chrome (--host-resolver-rules), csv, or tsv.
+      With import it's the input format: dnsmasq, unbound, or bind. With
+      apply it's json (default), csv, or tsv
+    -port with dump -format curl is a list of ports (default 80,443)
+    -match with dump only exports hostnames matching a pattern like
+      *.example.com
+    -network with dump only exports IPs in a range like 10.0.0.0/8
+    -origin with dump -format bind is the domain for the forward zone, e.g.
+      example.internal. Reverse zones are included for each /24 or /64.
+      With import -format bind it's used for relative names if the zone file
+      has no $ORIGIN
+    -serial with dump -format bind sets the SOA serial (default: the Unix
+      time, so it goes up each time you export)
 
 Configuration
 
     HOSTESS_FMT may be set to unix or windows to force that platform's syntax
     HOSTESS_PATH may be set to point to a file other than the platform default
+    HOSTESS_BACKUP_DIR may be set to keep backups somewhere other than the
+      directory containing the hosts file
+    HOSTESS_BACKUPS may be set to the number of backups to keep (default 5).
+      Set it to 0 to disable backups.
+    HOSTESS_NAMES may be set to lenient to accept new hostnames that are not
+      valid according to RFC 1123, e.g. my_host, or to strict to also reject
+      them in the hosts file
+    HOSTESS_EXPIRE may be set to disable to disable expired entries instead
+      of removing them
+    HOSTESS_BLOCK may be set to a name like hostess to only manage the entries
+      between # BEGIN hostess and # END hostess, and leave the rest of the
+      hosts file alone
 
 About
 
@@ -51,6 +130,20 @@ var (
 	ErrInvalidCommand = errors.New("invalid command")
 )
 
+// mutatingCommands change the hosts file, so we hold the hosts file lock
+// while they run.
+var mutatingCommands = map[string]bool{
+	"fmt":     true,
+	"add":     true,
+	"rm":      true,
+	"on":      true,
+	"off":     true,
+	"apply":   true,
+	"import":  true,
+	"gc":      true,
+	"restore": true,
+}
+
 func ExitWithError(err error) {
 	if err != nil {
 		os.Stderr.WriteString(err.Error())
@@ -60,17 +153,51 @@ func ExitWithError(err error) {
 }
 
 func Usage() {
-	fmt.Print(help, hostess.GetHostsPath())
-	os.Exit(0)
+	fmt.Printf(help, hostess.GetHostsPath())
 }
 
 func CommandUsage(command string) error {
 	return fmt.Errorf("Usage: %s %s <hostname>", os.Args[0], command)
 }
 
+// metadataFlag collects repeated -meta key=value flags
+type metadataFlag map[string]string
+
+func (m metadataFlag) String() string {
+	return ""
+}
+
+func (m metadataFlag) Set(value string) error {
+	equals := strings.Index(value, "=")
+	if equals < 1 {
+		return fmt.Errorf("expected key=value, found %q", value)
+	}
+	m[value[:equals]] = value[equals+1:]
+	return nil
+}
+
 func wrappedMain(args []string) error {
 	cli := flag.NewFlagSet(args[0], flag.ExitOnError)
 	preview := cli.Bool("n", false, "preview")
+	previewFull := cli.Bool("full", false, "preview entire file")
+	color := cli.Bool("color", false, "colorize preview")
+	check := cli.Bool("check", false, "check formatting")
+	lockTimeout := cli.Duration("lock-timeout", 10*time.Second, "lock timeout")
+	profile := cli.String("profile", "", "profile")
+	ttl := cli.Duration("ttl", 0, "expire after")
+	until := cli.String("until", "", "expire at")
+	comment := cli.String("comment", "", "comment")
+	metadata := metadataFlag{}
+	cli.Var(metadata, "meta", "metadata")
+	prune := cli.Bool("prune", false, "remove unlisted entries")
+	jsonOutput := cli.Bool("json", false, "output JSON")
+	planFile := cli.String("plan", "", "apply plan")
+	format := cli.String("format", "", "dump format")
+	origin := cli.String("origin", "", "zone origin")
+	serial := cli.Uint("serial", 0, "SOA serial")
+	ports := cli.String("port", "80,443", "ports")
+	match := cli.String("match", "", "domain pattern")
+	network := cli.String("network", "", "IP range")
 	cli.Usage = Usage
 
 	command := ""
@@ -78,14 +205,52 @@ func wrappedMain(args []string) error {
 		command = args[1]
 	} else {
 		Usage()
+		return nil
 	}
 
 	if err := cli.Parse(args[2:]); err != nil {
 		return err
 	}
 
+	// -ttl 0 looks the same as no -ttl to Add, so we check for it here
+	ttlSet := false
+	cli.Visit(func(f *flag.Flag) {
+		ttlSet = ttlSet || f.Name == "ttl"
+	})
+	if ttlSet && *ttl <= 0 {
+		return errors.New("-ttl must be positive, e.g. 2h")
+	}
+
 	options := &Options{
-		Preview: *preview,
+		Preview:     *preview,
+		PreviewFull: *previewFull,
+		Color:       *color,
+		Check:       *check,
+		LockTimeout: *lockTimeout,
+		Profile:     *profile,
+		TTL:         *ttl,
+		Until:       *until,
+		Comment:     *comment,
+		Metadata:    metadata,
+		Prune:       *prune,
+		JSON:        *jsonOutput,
+		Plan:        *planFile,
+		Format:      *format,
+		Origin:      *origin,
+		Serial:      *serial,
+		Ports:       *ports,
+		Match:       *match,
+		Network:     *network,
+	}
+
+	// -n and -check never write, so they don't need to wait for anyone else
+	mutating := mutatingCommands[command] || (command == "profile" && cli.Arg(0) != "ls")
+	if mutating && !options.Preview && !options.Check {
+		unlock, err := LockHostfile(options)
+		if err != nil {
+			return err
+		}
+		defer unlock()
 	}
 
 	switch command {
@@ -99,6 +264,9 @@ func wrappedMain(args []string) error {
 		return nil
 
 	case "fmt":
+		if options.Check {
+			return FormatCheck(options, cli.Args())
+		}
 		return Format(options)
 
 	case "add":
@@ -125,6 +293,9 @@ func wrappedMain(args []string) error {
 		}
 		return Disable(options, cli.Arg(0))
 
+	case "gc":
+		return GC(options)
+
 	case "ls":
 		return List(options)
 
@@ -138,11 +309,57 @@ func wrappedMain(args []string) error {
 		return Dump(options)
 
 	case "apply":
+		if options.Plan != "" {
+			return ApplyPlan(options, options.Plan)
+		}
 		if cli.Arg(0) == "" {
 			return fmt.Errorf("Usage: %s apply <filename>", args[0])
 		}
 		return Apply(options, cli.Arg(0))
 
+	case "diff":
+		if len(cli.Args()) != 2 {
+			return fmt.Errorf("Usage: %s diff <fileA> <fileB>", cli.Name())
+		}
+		return Diff(options, cli.Arg(0), cli.Arg(1))
+
+	case "import":
+		if cli.Arg(0) == "" {
+			return fmt.Errorf("Usage: %s import -format dnsmasq|unbound|bind <filename>", cli.Name())
+		}
+		return Import(options, cli.Arg(0))
+
+	case "plan":
+		if cli.Arg(0) == "" {
+			return fmt.Errorf("Usage: %s plan <filename>", args[0])
+		}
+		return Plan(options, cli.Arg(0))
+
+	case "profile":
+		if cli.Arg(0) == "ls" {
+			return ProfileList(options)
+		}
+		if cli.Arg(1) == "" {
+			return fmt.Errorf("Usage: %s profile create|enable|disable|ls|rm <name>", cli.Name())
+		}
+		switch cli.Arg(0) {
+		case "create":
+			return ProfileCreate(options, cli.Arg(1))
+		case "enable":
+			return ProfileEnable(options, cli.Arg(1))
+		case "disable":
+			return ProfileDisable(options, cli.Arg(1))
+		case "rm":
+			return ProfileRemove(options, cli.Arg(1))
+		}
+		return ErrInvalidCommand
+
+	case "backups":
+		return Backups(options)
+
+	case "restore":
+		return Restore(options, cli.Arg(0))
+
 	default:
 		return ErrInvalidCommand
 	}
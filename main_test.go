@@ -7,8 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cbednarski/hostess/hostess"
 )
@@ -47,14 +49,52 @@ func CopyHostsFile(t *testing.T, fixtureFiles ...string) (string, func()) {
 		t.Fatal(err)
 	}
 
+	// Keep backups out of the system temp directory
+	backupDir, err := ioutil.TempDir("", "hostess-test-backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv(hostess.EnvHostessBackupDir, backupDir); err != nil {
+		t.Fatal(err)
+	}
+
 	cleanup := func() {
 		os.Remove(temp.Name())
+		os.Remove(hostess.LockPath(temp.Name()))
+		os.RemoveAll(backupDir)
 		os.Unsetenv(hostess.EnvHostessPath)
+		os.Unsetenv(hostess.EnvHostessBackupDir)
 	}
 
 	return temp.Name(), cleanup
 }
 
+// CaptureStdout runs f and returns whatever it printed to stdout
+func CaptureStdout(t *testing.T, f func() error) (string, error) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(reader)
+		output <- data
+	}()
+
+	err = f()
+	writer.Close()
+	return string(<-output), err
+}
+
 func TestFormat(t *testing.T) {
 	temp, cleanup := CopyHostsFile(t)
 	defer cleanup()
@@ -69,9 +109,12 @@ func TestFormat(t *testing.T) {
 	}
 	output := string(data)
 
+	// Comments and blank lines stay where they were
 	expected := `127.0.0.1 localhost myapp.local
 127.0.1.1 ubuntu
 192.168.0.30 raspberrypi
+
+# The following lines are desirable for IPv6 capable hosts
 ::1 ip6-localhost ip6-loopback
 fe00:: ip6-localnet
 ff00:: ip6-mcastprefix
@@ -84,6 +127,8 @@ ff02::2 ip6-allrouters
 127.0.0.1 myapp.local
 127.0.1.1 ubuntu
 192.168.0.30 raspberrypi
+
+# The following lines are desirable for IPv6 capable hosts
 ::1 ip6-localhost
 ::1 ip6-loopback
 fe00:: ip6-localnet
@@ -118,33 +163,22 @@ func TestAddHostname(t *testing.T) {
 	}
 	output := string(data)
 
-	expected := `127.0.0.1 localhost my.new.website
-127.0.1.1 ubuntu
+	// Entries we didn't touch keep their original whitespace, comments are
+	// preserved, and new entries are appended to the end of the file.
+	expected := `127.0.0.1 localhost
 10.20.0.23 myapp.local
-192.168.0.30 raspberrypi
-192.168.0.82 mediaserver
-::1 ip6-localhost ip6-loopback
-fe00:: ip6-localnet
-ff00:: ip6-mcastprefix
+127.0.1.1	ubuntu
+192.168.0.30	raspberrypi
+
+# The following lines are desirable for IPv6 capable hosts
+::1     ip6-localhost ip6-loopback
+fe00::0 ip6-localnet
+ff00::0 ip6-mcastprefix
 ff02::1 ip6-allnodes
 ff02::2 ip6-allrouters
-`
-
-	if runtime.GOOS == "windows" {
-		expected = `127.0.0.1 localhost
 127.0.0.1 my.new.website
-127.0.1.1 ubuntu
-10.20.0.23 myapp.local
-192.168.0.30 raspberrypi
 192.168.0.82 mediaserver
-::1 ip6-localhost
-::1 ip6-loopback
-fe00:: ip6-localnet
-ff00:: ip6-mcastprefix
-ff02::1 ip6-allnodes
-ff02::2 ip6-allrouters
 `
-	}
 
 	if output != expected {
 		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
@@ -169,25 +203,15 @@ func TestRemoveHostname(t *testing.T) {
 	output := string(data)
 
 	expected := `127.0.0.1 localhost
-127.0.1.1 ubuntu
-::1 ip6-localhost ip6-loopback
-fe00:: ip6-localnet
-ff00:: ip6-mcastprefix
-ff02::1 ip6-allnodes
-ff02::2 ip6-allrouters
-`
+127.0.1.1	ubuntu
 
-	if runtime.GOOS == "windows" {
-		expected = `127.0.0.1 localhost
-127.0.1.1 ubuntu
-::1 ip6-localhost
-::1 ip6-loopback
-fe00:: ip6-localnet
-ff00:: ip6-mcastprefix
+# The following lines are desirable for IPv6 capable hosts
+::1     ip6-localhost ip6-loopback
+fe00::0 ip6-localnet
+ff00::0 ip6-mcastprefix
 ff02::1 ip6-allnodes
 ff02::2 ip6-allrouters
 `
-	}
 
 	if output != expected {
 		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
@@ -213,28 +237,16 @@ func TestHostnameOff(t *testing.T) {
 
 	expected := `127.0.0.1 localhost
 # 127.0.0.1 myapp.local
-127.0.1.1 ubuntu
+127.0.1.1	ubuntu
 # 192.168.0.30 raspberrypi
-::1 ip6-localhost ip6-loopback
-fe00:: ip6-localnet
-ff00:: ip6-mcastprefix
-ff02::1 ip6-allnodes
-ff02::2 ip6-allrouters
-`
 
-	if runtime.GOOS == "windows" {
-		expected = `127.0.0.1 localhost
-# 127.0.0.1 myapp.local
-127.0.1.1 ubuntu
-# 192.168.0.30 raspberrypi
-::1 ip6-localhost
-::1 ip6-loopback
-fe00:: ip6-localnet
-ff00:: ip6-mcastprefix
+# The following lines are desirable for IPv6 capable hosts
+::1     ip6-localhost ip6-loopback
+fe00::0 ip6-localnet
+ff00::0 ip6-mcastprefix
 ff02::1 ip6-allnodes
 ff02::2 ip6-allrouters
 `
-	}
 
 	if output != expected {
 		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
@@ -271,11 +283,17 @@ func TestExitCodeFmt(t *testing.T) {
 
 	finalExpected := `127.0.0.1 localhost kubernetes.docker.internal
 ::1 localhost
+# Added by Docker Desktop
+# To allow the same kube context to work on the host and the container:
+# End of section
 `
 	if runtime.GOOS == "windows" {
 		finalExpected = `127.0.0.1 localhost
 127.0.0.1 kubernetes.docker.internal
 ::1 localhost
+# Added by Docker Desktop
+# To allow the same kube context to work on the host and the container:
+# End of section
 `
 	}
 
@@ -289,6 +307,279 @@ func TestExitCodeFmt(t *testing.T) {
 	}
 }
 
+func TestUnparsedLines(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	// Lines we can't parse are reported, but they don't stop us from reading
+	// or changing the rest of the file
+	data := "127.0.0.1 localhost\n10.0.0.1\n10.0.0.999 broken.local\n10.0.0.2 myapp.local\n"
+	if err := ioutil.WriteFile(temp, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "ls"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "myapp.local") {
+		t.Errorf("Expected ls to show myapp.local, found:\n%s", output)
+	}
+
+	if _, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "off", "myapp.local"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "127.0.0.1 localhost\n10.0.0.1\n10.0.0.999 broken.local\n# 10.0.0.2 myapp.local\n"
+	if string(saved) != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, saved)
+	}
+
+	// Conflicts are still errors
+	if err := ioutil.WriteFile(temp, []byte("10.0.0.1 api.local\n10.0.0.2 api.local\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := wrappedMain([]string{"hostess", "has", "api.local"}); err != ErrParsingHostsFile {
+		t.Errorf("Expected %q, found %v", ErrParsingHostsFile, err)
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	domains := []string{"one.local", "two.local", "three.local", "four.local", "five.local"}
+
+	errs := make(chan error, len(domains))
+	for _, domain := range domains {
+		go func(domain string) {
+			errs <- wrappedMain([]string{"hostess", "add", domain, "10.0.0.1"})
+		}(domain)
+	}
+	for range domains {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, domain := range domains {
+		if !strings.Contains(string(data), domain) {
+			t.Errorf("Expected to find %s in:\n%s", domain, data)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	original, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wrappedMain(strings.Split("hostess rm myapp.local", " ")); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := hostess.ListBackups(hostess.GetBackupDir(), temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, found %d", len(backups))
+	}
+
+	// -n should not change anything
+	removed, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wrappedMain([]string{"hostess", "restore", "-n"}); err != nil {
+		t.Fatal(err)
+	}
+	previewed, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(removed, previewed) {
+		t.Error("Expected restore -n not to change the hosts file")
+	}
+
+	if err := wrappedMain([]string{"hostess", "restore", backups[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, restored) {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", original, restored)
+	}
+
+	// The restore itself should have been backed up
+	backups, err = hostess.ListBackups(hostess.GetBackupDir(), temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, found %d", len(backups))
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+	os.Setenv(hostess.EnvHostessBackups, "2")
+	defer os.Unsetenv(hostess.EnvHostessBackups)
+
+	for _, command := range []string{"hostess rm myapp.local", "hostess rm raspberrypi", "hostess rm ubuntu"} {
+		if err := wrappedMain(strings.Split(command, " ")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := hostess.ListBackups(hostess.GetBackupDir(), temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, found %d", len(backups))
+	}
+	// The newest backup is the hosts file before we removed ubuntu
+	data, err := backups[0].Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ubuntu") || strings.Contains(string(data), "raspberrypi") {
+		t.Errorf("Unexpected newest backup:\n%s", data)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	original, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess add -n mediaserver 192.168.0.82", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "--- " + temp + `
++++ ` + temp + ` (preview)
+@@ -8,3 +8,4 @@
+ ff00::0 ip6-mcastprefix
+ ff02::1 ip6-allnodes
+ ff02::2 ip6-allrouters
++192.168.0.82 mediaserver
+Added mediaserver -> 192.168.0.82 (On)
+`
+	if output != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess add -n -full mediaserver 192.168.0.82", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(output, string(original)+"192.168.0.82 mediaserver\n") {
+		t.Errorf("Expected -full to show the entire file, found:\n%s", output)
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, data) {
+		t.Error("Expected -n not to change the hosts file")
+	}
+}
+
+func TestFormatCheck(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	original, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "fmt", "-check"})
+	})
+	if err != ErrNotFormatted {
+		t.Fatalf("Expected %q, found %v", ErrNotFormatted, err)
+	}
+	if !strings.Contains(output, "+fe00:: ip6-localnet\n") {
+		t.Errorf("Expected diff in output, found:\n%s", output)
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, data) {
+		t.Error("Expected fmt -check not to change the hosts file")
+	}
+
+	if err := wrappedMain([]string{"hostess", "fmt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "fmt", "-check", temp})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "" {
+		t.Errorf("Expected no output for a formatted file, found:\n%s", output)
+	}
+
+	// Duplicates should fail the check even though fmt could fix them
+	if err := FormatCheck(&Options{Check: true}, []string{filepath.Join("testdata", "issue39")}); err != ErrNotFormatted {
+		t.Errorf("Expected %q, found %v", ErrNotFormatted, err)
+	}
+
+	// Comments and blank lines don't fail the check
+	fragment, err := ioutil.TempFile("", "hostess-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fragment.Name())
+	if _, err := fragment.WriteString(`# Staging services, see INC-1234
+
+10.1.2.3 api.staging.local
+10.1.2.4 web.staging.local
+`); err != nil {
+		t.Fatal(err)
+	}
+	fragment.Close()
+	if err := FormatCheck(&Options{Check: true}, []string{fragment.Name()}); err != nil {
+		t.Errorf("Expected a formatted file with comments to pass, found %v", err)
+	}
+}
+
 func TestNoCommand(t *testing.T) {
 	if err := wrappedMain([]string{"main"}); err != nil {
 		t.Fatal(err)
@@ -300,3 +591,803 @@ func TestNoArgs(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUnicodeHostname(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	if err := wrappedMain([]string{"hostess", "add", "bücher.example", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wrappedMain([]string{"hostess", "add", "foo bar", "127.0.0.1"}); err == nil {
+		t.Error("Expected an error adding \"foo bar\"")
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(data), "\n127.0.0.1 xn--bcher-kva.example\n") {
+		t.Errorf("Expected punycode hostname at the end of the file, found:\n%s", data)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "ls"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "bücher.example ") {
+		t.Errorf("Expected ls to show the Unicode hostname, found:\n%s", output)
+	}
+}
+
+func TestLegacyHostnames(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(temp, []byte("127.0.0.1 localhost\n10.0.0.2 my_host\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names already in the hosts file don't stop us from reading it...
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "ls"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "my_host") {
+		t.Errorf("Expected ls to show my_host, found:\n%s", output)
+	}
+	// A dump of the hosts file can be applied to it
+	dump, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "dump"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumpfile := temp + ".json"
+	defer os.Remove(dumpfile)
+	if err := ioutil.WriteFile(dumpfile, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "apply", dumpfile})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "rm", "my_host"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ...but we won't add new ones unless HOSTESS_NAMES=lenient
+	if err := wrappedMain([]string{"hostess", "add", "other_host", "10.0.0.3"}); err == nil {
+		t.Error("Expected an error adding other_host")
+	}
+}
+
+func TestDomainCase(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	original, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// myapp.local is already in the hosts file with this IP
+	if err := wrappedMain(strings.Split("hostess add MyApp.Local. 127.0.0.1", " ")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, data) {
+		t.Errorf("Expected the hosts file not to change, found:\n%s", data)
+	}
+
+	// Changing the IP keeps the original spelling
+	if err := wrappedMain(strings.Split("hostess add MYAPP.LOCAL 10.20.0.23", " ")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\n10.20.0.23 myapp.local\n") {
+		t.Errorf("Expected myapp.local to be updated, found:\n%s", data)
+	}
+
+	if err := wrappedMain(strings.Split("hostess rm MYAPP.LOCAL", " ")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "myapp.local") {
+		t.Errorf("Expected myapp.local to be removed, found:\n%s", data)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	commands := []string{
+		"hostess profile create staging",
+		"hostess profile create prod-debug",
+		"hostess add -profile staging api.example.com 10.1.2.3",
+		"hostess add --profile prod-debug api.example.com 52.1.2.3",
+		"hostess profile disable staging",
+		"hostess profile enable prod-debug",
+	}
+	for _, command := range commands {
+		if err := wrappedMain(strings.Split(command, " ")); err != nil {
+			t.Fatalf("%s: %s", command, err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# BEGIN profile staging
+# 10.1.2.3 api.example.com
+# END profile staging
+# BEGIN profile prod-debug
+52.1.2.3 api.example.com
+# END profile prod-debug
+`
+	if !strings.HasSuffix(string(data), "ff02::2 ip6-allrouters\n"+expected) {
+		t.Errorf("Expected profiles at the end of the file, found:\n%s", data)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess profile ls", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `staging    1 hostnames (Off)
+prod-debug 1 hostnames (On)
+`
+	if output != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
+	}
+
+	if err := wrappedMain(strings.Split("hostess profile rm staging", " ")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "staging") || strings.Contains(string(data), "10.1.2.3") {
+		t.Errorf("Expected staging to be removed, found:\n%s", data)
+	}
+
+	// Only one entry for a domain is enabled, whether or not it's in a profile
+	commands = []string{
+		"hostess add api.example.com 10.0.0.1",
+		"hostess add -profile staging api.example.com 10.1.2.3",
+		"hostess profile disable staging",
+		"hostess profile enable staging",
+	}
+	for _, command := range commands {
+		if _, err := CaptureStdout(t, func() error {
+			return wrappedMain(strings.Split(command, " "))
+		}); err != nil {
+			t.Fatalf("%s: %s", command, err)
+		}
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasSuffix(line, " api.example.com") && !strings.HasPrefix(line, "#") {
+			enabled = append(enabled, line)
+		}
+	}
+	if len(enabled) != 1 || enabled[0] != "10.1.2.3 api.example.com" {
+		t.Errorf("Expected only staging to be enabled, found:\n%s", data)
+	}
+
+	// on keeps the entry that is already enabled, or else picks the one that
+	// is not in a profile, and the hosts file can still be read afterwards
+	for _, command := range []string{"hostess on api.example.com", "hostess ls", "hostess profile disable staging", "hostess on api.example.com", "hostess ls"} {
+		if _, err := CaptureStdout(t, func() error {
+			return wrappedMain(strings.Split(command, " "))
+		}); err != nil {
+			t.Fatalf("%s: %s", command, err)
+		}
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\n10.0.0.1 api.example.com\n") || !strings.Contains(string(data), "\n# 10.1.2.3 api.example.com\n") {
+		t.Errorf("Expected only the entry outside staging to be enabled, found:\n%s", data)
+	}
+}
+
+func TestComments(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	args := []string{"hostess", "add", "-comment", "INC-1234 bypass CDN", "-meta", "owner=alice", "-meta", "ticket=INC-1234", "cdn.example.com", "10.0.0.9"}
+	if err := wrappedMain(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := wrappedMain(strings.Split("hostess add -meta expires=soon x.local 10.0.0.8", " ")); err == nil {
+		t.Error("Expected an error for the reserved expires key")
+	}
+	// A comment that ends like an annotation would come back as metadata, or
+	// not parse at all
+	for _, comment := range []string{"until [expires=friday]", "ticket [id=42]"} {
+		if err := wrappedMain([]string{"hostess", "add", "-comment", comment, "w.local", "1.2.3.4"}); err == nil {
+			t.Errorf("Expected an error for -comment %q", comment)
+		}
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "10.0.0.9 cdn.example.com # INC-1234 bypass CDN [owner=alice ticket=INC-1234]") {
+		t.Errorf("Expected an inline comment, found:\n%s", data)
+	}
+	if strings.Contains(string(data), "w.local") {
+		t.Errorf("Expected w.local not to be added, found:\n%s", data)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess ls", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "(On) # INC-1234 bypass CDN [owner=alice ticket=INC-1234]") {
+		t.Errorf("Expected ls to show the comment, found:\n%s", output)
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"comment": "INC-1234 bypass CDN"`) || !strings.Contains(output, `"owner": "alice"`) {
+		t.Errorf("Expected dump to include the comment and metadata, found:\n%s", output)
+	}
+}
+
+func TestManagedBlockList(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+	_, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	if _, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "add", "api.local", "10.0.0.1"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The block only limits what we change, so ls and has see everything
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "ls"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "api.local       -> 10.0.0.1     (On)\n") {
+		t.Errorf("Expected api.local in ls, found:\n%s", output)
+	}
+	if !strings.Contains(output, "raspberrypi     -> 192.168.0.30 (On) (unmanaged)\n") {
+		t.Errorf("Expected raspberrypi to be marked unmanaged, found:\n%s", output)
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "has", "raspberrypi"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(output, "Found raspberrypi") {
+		t.Errorf("Expected has to find raspberrypi, found:\n%s", output)
+	}
+}
+
+func TestApplyPrune(t *testing.T) {
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	desired, err := ioutil.TempFile("", "hostess-desired-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(desired.Name())
+	writeDesired := func(data string) {
+		if err := ioutil.WriteFile(desired.Name(), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeDesired(`[{"domain": "api.local", "ip": "10.0.0.1", "enabled": true}, {"domain": "db.local", "ip": "10.0.0.2", "enabled": true}]`)
+	if err := wrappedMain([]string{"hostess", "apply", desired.Name()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing db.local from the desired state removes it from the hosts file
+	writeDesired(`[{"domain": "api.local", "ip": "10.0.0.1", "enabled": true}]`)
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "apply", "-prune", desired.Name()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Removed db.local -> 10.0.0.2 (On)\n" + desired.Name() + " applied\n"; output != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the managed block was pruned
+	if !strings.Contains(string(data), "127.0.0.1	localhost myapp.local") {
+		t.Errorf("Expected entries outside the block to be kept, found:\n%s", data)
+	}
+	if !strings.Contains(string(data), "# BEGIN hostess\n10.0.0.1 api.local\n# END hostess\n") {
+		t.Errorf("Expected only api.local in the block, found:\n%s", data)
+	}
+
+	// Without a managed block we'd remove entries hostess doesn't manage, like
+	// the machine's own hostname, so we refuse
+	os.Unsetenv(hostess.EnvHostessBlock)
+	for _, command := range []string{"apply", "plan"} {
+		if err := wrappedMain([]string{"hostess", command, "-prune", "-n", desired.Name()}); err == nil {
+			t.Errorf("Expected %s -prune to fail without a managed block", command)
+		}
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	desired, err := ioutil.TempFile("", "hostess-desired-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(desired.Name())
+	if _, err := desired.WriteString(`[{"domain": "myapp.local", "ip": "10.0.0.9", "enabled": true}]`); err != nil {
+		t.Fatal(err)
+	}
+	desired.Close()
+
+	// old.local has already expired, and soon.local expires after we make the
+	// plan but before we apply it
+	soon := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, "10.0.0.8 old.local # [expires=2020-01-01T00:00:00Z]\n"...)
+	data = append(data, "10.0.0.7 soon.local # [expires="+soon.UTC().Format(time.RFC3339)+"]\n"...)
+	if err := ioutil.WriteFile(temp, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "plan", desired.Name()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "update  myapp.local -> 127.0.0.1 (On) => 10.0.0.9 (On)") {
+		t.Errorf("Expected myapp.local to be updated, found:\n%s", output)
+	}
+	if !strings.Contains(output, "remove  old.local -> 10.0.0.8 (On)") {
+		t.Errorf("Expected the plan to remove expired old.local, found:\n%s", output)
+	}
+
+	planJSON, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "plan", "-json", desired.Name()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := ioutil.TempFile("", "hostess-plan-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(plan.Name())
+	if _, err := plan.WriteString(planJSON); err != nil {
+		t.Fatal(err)
+	}
+	plan.Close()
+
+	time.Sleep(time.Until(soon) + 50*time.Millisecond)
+	if err := wrappedMain([]string{"hostess", "apply", "-plan", plan.Name()}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "10.0.0.9 myapp.local") || strings.Contains(string(data), "old.local") {
+		t.Errorf("Expected myapp.local to be updated and old.local removed, found:\n%s", data)
+	}
+	// soon.local wasn't in the plan, so apply -plan leaves it for the next
+	// command to sweep
+	if !strings.Contains(string(data), "10.0.0.7 soon.local") {
+		t.Errorf("Expected apply -plan to only make the planned changes, found:\n%s", data)
+	}
+
+	// The hosts file changed, so the same plan can't be applied again
+	if err := wrappedMain([]string{"hostess", "apply", "-plan", plan.Name()}); err == nil {
+		t.Error("Expected an error applying a stale plan")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	// Legacy names in the hosts file are in its dump too
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(temp, append(data, "10.0.0.2 my_host\n"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumpfile, err := ioutil.TempFile("", "hostess-dump-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dumpfile.Name())
+	if _, err := dumpfile.WriteString(dump); err != nil {
+		t.Fatal(err)
+	}
+	dumpfile.Close()
+
+	// A hosts file and its own dump have the same entries
+	if err := wrappedMain([]string{"hostess", "diff", temp, dumpfile.Name()}); err != nil {
+		t.Errorf("Expected no differences, found %s", err)
+	}
+
+	if err := wrappedMain(strings.Split("hostess add api.local 10.0.0.1", " ")); err != nil {
+		t.Fatal(err)
+	}
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "diff", dumpfile.Name(), temp})
+	})
+	if err != ErrHostsDiffer {
+		t.Errorf("Expected ErrHostsDiffer, found %v", err)
+	}
+	if output != "add     api.local -> 10.0.0.1 (On)\n" {
+		t.Errorf("Expected api.local to be added, found:\n%s", output)
+	}
+}
+
+func TestDumpFormats(t *testing.T) {
+	_, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump -format dnsmasq", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stock Ubuntu entries for localhost, 127.0.1.1, and the ip6- names
+	// only mean something on this machine, so they're left out
+	if output != "host-record=raspberrypi,192.168.0.30\n" {
+		t.Errorf("Expected only raspberrypi, found:\n%s", output)
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump -format coredns", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "hosts {\n    192.168.0.30 raspberrypi\n    fallthrough\n}\n"
+	if output != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
+	}
+
+	// The container formats leave out the same entries
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump -format kubernetes", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "hostAliases:\n- ip: \"192.168.0.30\"\n  hostnames:\n  - \"raspberrypi\"\n"
+	if output != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
+	}
+	for _, format := range []string{"docker", "compose"} {
+		output, err = CaptureStdout(t, func() error {
+			return wrappedMain([]string{"hostess", "dump", "-format", format})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(output, "raspberrypi:192.168.0.30") || strings.Contains(output, "localhost") || strings.Contains(output, "ubuntu") || strings.Contains(output, "ip6-") {
+			t.Errorf("Expected only raspberrypi in %s, found:\n%s", format, output)
+		}
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump -format bind -origin local", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "; zone local\n$ORIGIN local.\n") || !strings.Contains(output, "myapp\tIN\tA\t127.0.0.1\n") {
+		t.Errorf("Expected a zone for local, found:\n%s", output)
+	}
+	if !strings.Contains(output, "; zone 0.0.127.in-addr.arpa\n") {
+		t.Errorf("Expected a reverse zone for 127.0.0.1, found:\n%s", output)
+	}
+	// Without -serial the SOA serial is the Unix time, so it goes up each time
+	fields := strings.Fields(output[strings.Index(output, "(")+1:])
+	serial, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || serial < time.Now().Add(-time.Hour).Unix() {
+		t.Errorf("Expected the Unix time as the serial, found %s", fields[0])
+	}
+	if err := wrappedMain(strings.Split("hostess dump -format bind", " ")); err == nil {
+		t.Error("Expected an error without -origin")
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump -format bind -origin local -serial 2026101701", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(output, "\t\t2026101701\t; serial\n") != 2 {
+		t.Errorf("Expected serial 2026101701 in both zones, found:\n%s", output)
+	}
+	if err := wrappedMain(strings.Split("hostess dump -format bind -origin local -serial 4294967296", " ")); err == nil {
+		t.Error("Expected an error for a serial that doesn't fit in 32 bits")
+	}
+
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess dump -format curl -port 8080 -match myapp.*", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "--resolve myapp.local:8080:127.0.0.1\n" {
+		t.Errorf("Expected one --resolve flag for myapp.local, found:\n%s", output)
+	}
+	if err := wrappedMain(strings.Split("hostess dump -format curl -port http", " ")); err == nil {
+		t.Error("Expected an error for an invalid port")
+	}
+
+	if err := wrappedMain(strings.Split("hostess dump -format nope", " ")); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestImport(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	config, err := ioutil.TempFile("", "hostess-dnsmasq-*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(config.Name())
+	if _, err := config.WriteString("address=/api.local/10.0.0.1\nhost-record=myapp.local,10.0.0.2\n"); err != nil {
+		t.Fatal(err)
+	}
+	config.Close()
+
+	// -n shows the changes without making them
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "import", "-n", "-format", "dnsmasq", config.Name()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "+10.0.0.1 api.local") {
+		t.Errorf("Expected a preview adding api.local, found:\n%s", output)
+	}
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "api.local") {
+		t.Errorf("Expected -n not to change the hosts file, found:\n%s", data)
+	}
+
+	if err := wrappedMain([]string{"hostess", "import", "-format", "dnsmasq", config.Name()}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// myapp.local conflicted with 127.0.0.1, and the imported entry wins
+	if !strings.Contains(string(data), "10.0.0.1 api.local") || !strings.Contains(string(data), "10.0.0.2 myapp.local") {
+		t.Errorf("Expected imported entries, found:\n%s", data)
+	}
+
+	if err := wrappedMain([]string{"hostess", "import", config.Name()}); err == nil {
+		t.Error("Expected an error without -format")
+	}
+
+	// Records that can't be parsed are reported and skipped
+	zone, err := ioutil.TempFile("", "hostess-*.zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(zone.Name())
+	if _, err := zone.WriteString("$ORIGIN example.internal.\napi 1h IN A 10.0.0.50\nbad 1x IN A 10.0.0.51\n"); err != nil {
+		t.Fatal(err)
+	}
+	zone.Close()
+	output, err = CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "import", "-format", "bind", zone.Name()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "Imported 1 hostnames from "+zone.Name()+"\n" {
+		t.Errorf("Expected one imported hostname, found:\n%s", output)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "10.0.0.50 api.example.internal") || strings.Contains(string(data), "bad.example.internal") {
+		t.Errorf("Expected only api.example.internal to be imported, found:\n%s", data)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain([]string{"hostess", "dump", "-format", "csv"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(output, "domain,ip,enabled,comment\nlocalhost,127.0.0.1,true,\n") {
+		t.Errorf("Expected CSV with a header row, found:\n%s", output)
+	}
+
+	// The spreadsheet is the source of truth for the managed block
+	os.Setenv(hostess.EnvHostessBlock, "hostess")
+	defer os.Unsetenv(hostess.EnvHostessBlock)
+
+	sheet, err := ioutil.TempFile("", "hostess-*.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(sheet.Name())
+	sheet.Close()
+	applySheet := func(data string, args ...string) string {
+		if err := ioutil.WriteFile(sheet.Name(), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(append([]string{"hostess", "apply", "-format", "tsv"}, args...), sheet.Name())
+		output, err := CaptureStdout(t, func() error {
+			return wrappedMain(args)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output
+	}
+
+	applySheet("domain\tip\tenabled\tcomment\napi.local\t10.0.0.1\tyes\tINC-1234\ndb.local\t10.0.0.2\tyes\t\n")
+	output = applySheet("domain\tip\tenabled\tcomment\napi.local\t10.0.0.1\tyes\tINC-1234\n", "-prune")
+	if expected := "Removed db.local -> 10.0.0.2 (On)\n" + sheet.Name() + " applied\n"; output != expected {
+		t.Errorf("--- Expected ---\n%s\n--- Found ---\n%s\n", expected, output)
+	}
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# BEGIN hostess\n10.0.0.1 api.local # INC-1234\n# END hostess\n") || !strings.Contains(string(data), "myapp.local") {
+		t.Errorf("Expected the managed block to match the TSV, found:\n%s", data)
+	}
+
+	if err := wrappedMain([]string{"hostess", "apply", "-format", "xml", sheet.Name()}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestExpiringEntries(t *testing.T) {
+	temp, cleanup := CopyHostsFile(t)
+	defer cleanup()
+
+	const expired = "10.9.9.8 old.local # [expires=2020-01-01T00:00:00Z]\n"
+	appendExpired := func() {
+		data, err := ioutil.ReadFile(temp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(temp, append(data, expired...), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendExpired()
+	if err := wrappedMain(strings.Split("hostess add -ttl 2h incident.local 10.9.9.9", " ")); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{
+		"hostess add -ttl 1h -until 2020-01-01 x.local 10.9.9.7",
+		"hostess add -until 2020-01-01T00:00Z x.local 10.9.9.7",
+		"hostess add -ttl 0 x.local 10.9.9.7",
+		"hostess add -ttl -5m x.local 10.9.9.7",
+	} {
+		if err := wrappedMain(strings.Split(command, " ")); err == nil {
+			t.Errorf("%s: expected an error", command)
+		}
+	}
+
+	output, err := CaptureStdout(t, func() error {
+		return wrappedMain(strings.Split("hostess ls", " "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "10.9.9.9     (On) (expires in 1h59m)") {
+		t.Errorf("Expected incident.local to expire in 1h59m, found:\n%s", output)
+	}
+	// old.local had already expired, so it was swept before saving
+	if strings.Contains(output, "old.local") {
+		t.Errorf("Expected old.local to be removed, found:\n%s", output)
+	}
+
+	data, err := ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "10.9.9.9 incident.local # [expires=") {
+		t.Errorf("Expected incident.local to have an expiry time, found:\n%s", data)
+	}
+
+	// With HOSTESS_EXPIRE=disable expired entries are kept but disabled
+	os.Setenv(hostess.EnvHostessExpire, "disable")
+	defer os.Unsetenv(hostess.EnvHostessExpire)
+	appendExpired()
+	if err := wrappedMain(strings.Split("hostess gc", " ")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# 10.9.9.8 old.local # [expires=2020-01-01T00:00:00Z]") {
+		t.Errorf("Expected old.local to be disabled, found:\n%s", data)
+	}
+}